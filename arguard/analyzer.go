@@ -2,11 +2,17 @@ package arguard
 
 import (
 	"errors"
+	"fmt"
 	"go/ast"
+	"go/token"
 	"go/types"
+	"path/filepath"
+	"strings"
 
 	"github.com/orsinium-labs/arguard/contracts"
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/types/typeutil"
 )
 
@@ -19,7 +25,7 @@ func NewAnalyzer(
 		Name:     "arguard",
 		Doc:      "statically finds function calls that will fail in runtime",
 		Run:      a.run,
-		Requires: []*analysis.Analyzer{contractsAnalyzer},
+		Requires: []*analysis.Analyzer{contractsAnalyzer, buildssa.Analyzer},
 		Flags:    *config.flagSet(),
 	}
 }
@@ -36,11 +42,20 @@ func (a analyzer) run(pass *analysis.Pass) (any, error) {
 		return nil, errors.New("contracts analyzer is required but was not run")
 	}
 	funcs := rawFuncs.(contracts.Result)
+	if !a.config.Packages.Allows(pass.Pkg.Path()) {
+		return nil, nil
+	}
+	ssaInfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	calls := findCalls(ssaInfo.SrcFuncs)
 	// analyze every file
 	for _, file := range pass.Files {
+		if a.config.SkipGenerated && isGenerated(pass.Fset, file) {
+			continue
+		}
 		fa := fileAnalyzer{
 			config: a.config,
 			funcs:  funcs,
+			calls:  calls,
 			pass:   pass,
 			file:   file,
 		}
@@ -49,9 +64,19 @@ func (a analyzer) run(pass *analysis.Pass) (any, error) {
 	return nil, nil
 }
 
+// isGenerated checks if the file is generated or vendored.
+func isGenerated(fset *token.FileSet, file *ast.File) bool {
+	if contracts.IsGenerated(file) {
+		return true
+	}
+	path := filepath.ToSlash(fset.File(file.Pos()).Name())
+	return strings.Contains(path, "/vendor/")
+}
+
 type fileAnalyzer struct {
 	config *Config
 	funcs  contracts.Result
+	calls  map[token.Pos]ssa.CallInstruction
 	pass   *analysis.Pass
 	file   *ast.File
 }
@@ -82,7 +107,12 @@ func (fa *fileAnalyzer) inspect(node ast.Node) {
 
 	// validate contracts
 	vars := fn.MapArgs(nCall.Args, fa.pass.TypesInfo)
-	contract, err := fn.Validate(vars)
+	ranges := fa.resolveArgs(nCall, fn, vars)
+	contract, err := fn.ValidateRanges(vars, ranges)
+	note := ""
+	if err == nil && contract == nil {
+		contract, note, err = fa.unroll(nCall, fn, vars, ranges)
+	}
 	if err != nil {
 		if fa.config.ReportErrors {
 			fa.pass.Reportf(node.Pos(), "error executing contracts: %v", err)
@@ -90,6 +120,153 @@ func (fa *fileAnalyzer) inspect(node ast.Node) {
 		return
 	}
 	if contract != nil {
-		fa.pass.Reportf(node.Pos(), "contract violated: %s", contract.Message)
+		fa.report(node.Pos(), contract, note)
+	}
+}
+
+// callArgs returns the SSA representation of the call and its arguments,
+// including the receiver for static method calls.
+//
+// The second value is the offset of the first argument in the call expression.
+func (fa *fileAnalyzer) callArgs(
+	nCall *ast.CallExpr,
+	fn *contracts.Function,
+) (ssa.CallInstruction, []ssa.Value, int) {
+	call, found := fa.calls[nCall.Lparen]
+	if !found || len(fn.Args) != len(nCall.Args) {
+		return nil, nil, 0
+	}
+	// for static method calls, the receiver is the first argument
+	args := call.Common().Args
+	offset := len(args) - len(nCall.Args)
+	if offset < 0 {
+		return nil, nil, 0
+	}
+	return call, args, offset
+}
+
+// resolveArgs adds into vars values of arguments that aren't constant expressions
+// but still statically known, like local variables with a constant value.
+//
+// For arguments that are not known, it returns ranges of their values
+// known from conditions of the branches that the call is inside of.
+func (fa *fileAnalyzer) resolveArgs(
+	nCall *ast.CallExpr,
+	fn *contracts.Function,
+	vars map[string]string,
+) map[string]contracts.Range {
+	call, args, offset := fa.callArgs(nCall, fn)
+	if call == nil {
+		return nil
+	}
+	r := newResolver(fa.pass.TypesSizes)
+	ranges := make(map[string]contracts.Range)
+	for i, name := range fn.Args {
+		if _, known := vars[name]; known {
+			continue
+		}
+		arg := args[offset+i]
+		val, ok := r.resolveArg(arg)
+		if ok {
+			vars[name] = val
+			continue
+		}
+		exact, rng, ok := r.argRange(arg, call.Block())
+		if exact != nil {
+			vars[name] = contracts.FormatConstant(exact)
+		} else if ok {
+			ranges[name] = rng
+		}
+	}
+	return ranges
+}
+
+// unroll checks the call inside of a loop with a small number of iterations
+// for every value of the loop variable, like `for i := 0; i < 3; i++`
+// or `for _, v := range []int{1, 2, 3}`.
+//
+// If a contract is violated on some iteration, it returns the contract
+// and the note with values of arguments on that iteration.
+func (fa *fileAnalyzer) unroll(
+	nCall *ast.CallExpr,
+	fn *contracts.Function,
+	vars map[string]string,
+	ranges map[string]contracts.Range,
+) (*contracts.Contract, string, error) {
+	if fa.config.MaxUnroll <= 0 || len(vars) == len(fn.Args) {
+		return nil, "", nil
+	}
+	call, args, offset := fa.callArgs(nCall, fn)
+	if call == nil {
+		return nil, "", nil
+	}
+	r := newResolver(fa.pass.TypesSizes)
+	l, ok := r.findLoop(call.Block(), fa.config.MaxUnroll)
+	if !ok {
+		return nil, "", nil
+	}
+	for _, val := range l.values {
+		r.fixed[l.phi] = val
+		if !r.reachable(call.Block(), l) {
+			continue
+		}
+		iterVars := make(map[string]string, len(fn.Args))
+		notes := make([]string, 0)
+		for i, name := range fn.Args {
+			if val, known := vars[name]; known {
+				iterVars[name] = val
+				continue
+			}
+			val, ok := r.resolveArg(args[offset+i])
+			if ok {
+				iterVars[name] = val
+				notes = append(notes, fmt.Sprintf("%s = %s", types.ExprString(nCall.Args[i]), val))
+			}
+		}
+		// arguments don't depend on the loop variable
+		if len(notes) == 0 {
+			continue
+		}
+		contract, err := fn.ValidateRanges(iterVars, ranges)
+		if err != nil || contract != nil {
+			return contract, "when " + strings.Join(notes, ", "), err
+		}
 	}
+	return nil, "", nil
+}
+
+// report reports the contract violation.
+//
+// If the contract is inherited from another function,
+// the diagnostic also points to the calls it is inherited through.
+// The note, if not empty, is added at the end of the message.
+func (fa *fileAnalyzer) report(pos token.Pos, contract *contracts.Contract, note string) {
+	if note != "" {
+		note = " (" + note + ")"
+	}
+	if len(contract.Chain) == 0 {
+		fa.pass.Reportf(pos, "contract violated: %s%s", contract.Message, note)
+		return
+	}
+	// positions of contracts imported from other packages are not known
+	related := make([]analysis.RelatedInformation, 0, len(contract.Chain)+1)
+	if contract.Pos.IsValid() {
+		related = append(related, analysis.RelatedInformation{
+			Pos:     contract.Pos,
+			Message: "inherited from " + contract.Chain[0].Name,
+		})
+	}
+	for _, call := range contract.Chain {
+		if call.Pos.IsValid() {
+			related = append(related, analysis.RelatedInformation{
+				Pos:     call.Pos,
+				Message: "in " + call.Name,
+			})
+		}
+	}
+	fa.pass.Report(analysis.Diagnostic{
+		Pos:     pos,
+		Message: fmt.Sprintf("contract violated: %s (via %s)%s", contract.Message, contract.Trace(), note),
+		Related: related,
+	})
 }
@@ -1,8 +1,10 @@
 package arguard_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/orsinium-labs/arguard/arguard"
@@ -18,6 +20,7 @@ func TestAll(t *testing.T) {
 	}
 
 	cConfig := contracts.NewConfig()
+	cConfig.CacheDir = t.TempDir()
 	cConfig.ReportContracts = true
 	cAnalyzer := contracts.NewAnalyzer(cConfig)
 	aConfig := arguard.NewConfig()
@@ -27,6 +30,23 @@ func TestAll(t *testing.T) {
 	analysistest.Run(t, testdata, aAnalyzer, "p")
 }
 
+func TestSkipGenerated(t *testing.T) {
+	t.Parallel()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+
+	cConfig := contracts.NewConfig()
+	cConfig.CacheDir = t.TempDir()
+	cAnalyzer := contracts.NewAnalyzer(cConfig)
+	aConfig := arguard.NewConfig()
+	aAnalyzer := arguard.NewAnalyzer(aConfig, cAnalyzer)
+
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, aAnalyzer, "gen")
+}
+
 // Run the linter on random stdlib packages and see if it explodes.
 func TestSmoke(t *testing.T) {
 	t.Parallel()
@@ -48,12 +68,30 @@ func TestSmoke(t *testing.T) {
 		t.Run(pkgName, func(t *testing.T) {
 			t.Parallel()
 			cConfig := contracts.NewConfig()
+			cConfig.CacheDir = t.TempDir()
 			cConfig.FollowImports = false
 			cAnalyzer := contracts.NewAnalyzer(cConfig)
 			aConfig := arguard.NewConfig()
 			aConfig.ReportErrors = true
 			aAnalyzer := arguard.NewAnalyzer(aConfig, cAnalyzer)
-			analysistest.Run(t, testdata, aAnalyzer, pkgName)
+			analysistest.Run(smokeT{t}, testdata, aAnalyzer, pkgName)
 		})
 	}
 }
+
+// smokeT ignores errors about want comments in stdlib packages.
+//
+// Some stdlib comments contain the word "want" that analysistest
+// fails to parse as expectations, and there are none to check anyway.
+type smokeT struct {
+	*testing.T
+}
+
+func (t smokeT) Errorf(format string, args ...any) {
+	t.Helper()
+	msg := fmt.Sprintf(format, args...)
+	if strings.Contains(msg, "in 'want' comment") {
+		return
+	}
+	t.T.Errorf("%s", msg)
+}
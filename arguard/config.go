@@ -1,14 +1,24 @@
 package arguard
 
-import "flag"
+import (
+	"flag"
+
+	"github.com/orsinium-labs/arguard/contracts"
+)
 
 type Config struct {
-	ReportErrors bool
+	ReportErrors  bool
+	SkipGenerated bool             // don't check generated and vendored files
+	Packages      contracts.Filter // packages to check
+	MaxUnroll     int              // max iterations of loops to check calls in for each iteration
 }
 
 func NewConfig() Config {
 	return Config{
-		ReportErrors: false,
+		ReportErrors:  false,
+		SkipGenerated: true,
+		Packages:      contracts.Filter{},
+		MaxUnroll:     100,
 	}
 }
 
@@ -18,5 +28,14 @@ func (c *Config) flagSet() *flag.FlagSet {
 		&c.ReportErrors, "report-errors", c.ReportErrors,
 		"show errors occurring during contract execution",
 	)
+	fs.BoolVar(
+		&c.SkipGenerated, "skip-generated", c.SkipGenerated,
+		"don't check generated files and files in vendor directories",
+	)
+	fs.IntVar(
+		&c.MaxUnroll, "max-unroll", c.MaxUnroll,
+		"max number of loop iterations to check calls inside of the loop for each iteration, 0 to disable",
+	)
+	c.Packages.AddFlags(fs)
 	return fs
 }
@@ -0,0 +1,266 @@
+package arguard
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/orsinium-labs/arguard/contracts"
+	"golang.org/x/tools/go/ssa"
+)
+
+// maxDepth limits how deep arithmetic expressions are traced.
+const maxDepth = 16
+
+// interval is a closed range of integer values, nil bounds are unbounded.
+type interval struct {
+	lo, hi constant.Value
+}
+
+func (iv interval) add(other interval) interval {
+	res := interval{}
+	if iv.lo != nil && other.lo != nil {
+		res.lo = constant.BinaryOp(iv.lo, token.ADD, other.lo)
+	}
+	if iv.hi != nil && other.hi != nil {
+		res.hi = constant.BinaryOp(iv.hi, token.ADD, other.hi)
+	}
+	return res
+}
+
+// scale multiplies all values in the interval by the constant.
+func (iv interval) scale(k constant.Value) interval {
+	mul := func(x constant.Value) constant.Value {
+		if x == nil {
+			return nil
+		}
+		return constant.BinaryOp(x, token.MUL, k)
+	}
+	switch constant.Sign(k) {
+	case 0:
+		return interval{k, k}
+	case -1:
+		return interval{mul(iv.hi), mul(iv.lo)}
+	}
+	return interval{mul(iv.lo), mul(iv.hi)}
+}
+
+// within checks if the interval is inside of the other one.
+func (iv interval) within(other interval) bool {
+	if other.lo != nil && (iv.lo == nil || constant.Compare(iv.lo, token.LSS, other.lo)) {
+		return false
+	}
+	if other.hi != nil && (iv.hi == nil || constant.Compare(iv.hi, token.GTR, other.hi)) {
+		return false
+	}
+	return true
+}
+
+// term is a value in a linear form with unknown value.
+type term struct {
+	value   ssa.Value
+	builtin string // if not empty, the term is this builtin (len or cap) called on the value
+}
+
+// linear is a linear form of integer values: sum of terms multiplied by coefficients plus a constant.
+//
+// It allows to calculate the range of expressions in which the same unknown value
+// is used multiple times, like `len(x) - len(x) - 1`.
+type linear struct {
+	terms map[term]constant.Value
+	c     constant.Value
+}
+
+func newLinear(c constant.Value) linear {
+	return linear{terms: make(map[term]constant.Value), c: c}
+}
+
+// plus returns the sum of the two forms, with the other one multiplied by k.
+func (l linear) plus(other linear, k constant.Value) linear {
+	res := newLinear(constant.BinaryOp(l.c, token.ADD, constant.BinaryOp(other.c, token.MUL, k)))
+	for t, coef := range l.terms {
+		res.terms[t] = coef
+	}
+	for t, coef := range other.terms {
+		coef = constant.BinaryOp(coef, token.MUL, k)
+		prev, found := res.terms[t]
+		if found {
+			coef = constant.BinaryOp(prev, token.ADD, coef)
+		}
+		if constant.Sign(coef) == 0 {
+			delete(res.terms, t)
+		} else {
+			res.terms[t] = coef
+		}
+	}
+	return res
+}
+
+// linear converts the integer value into a linear form.
+//
+// The calculation is done over unbounded integers. Since addition, subtraction,
+// and multiplication wrap around the same way for all integer types, the result
+// is the same as in runtime if it fits into the type.
+func (r resolver) linear(value ssa.Value, depth int) linear {
+	if val, ok := r.resolve(value); ok && val.Kind() == constant.Int {
+		return newLinear(val)
+	}
+	zero := constant.MakeInt64(0)
+	one := constant.MakeInt64(1)
+	if depth < maxDepth && isInteger(value.Type()) {
+		switch v := value.(type) {
+		case *ssa.BinOp:
+			switch v.Op {
+			case token.ADD:
+				return r.linear(v.X, depth+1).plus(r.linear(v.Y, depth+1), one)
+			case token.SUB:
+				return r.linear(v.X, depth+1).plus(r.linear(v.Y, depth+1), constant.MakeInt64(-1))
+			case token.MUL:
+				if k, ok := r.resolve(v.X); ok && k.Kind() == constant.Int {
+					return newLinear(zero).plus(r.linear(v.Y, depth+1), k)
+				}
+				if k, ok := r.resolve(v.Y); ok && k.Kind() == constant.Int {
+					return newLinear(zero).plus(r.linear(v.X, depth+1), k)
+				}
+			}
+		case *ssa.UnOp:
+			if v.Op == token.SUB {
+				return newLinear(zero).plus(r.linear(v.X, depth+1), constant.MakeInt64(-1))
+			}
+		case *ssa.Call:
+			builtin, ok := v.Call.Value.(*ssa.Builtin)
+			if ok && (builtin.Name() == "len" || builtin.Name() == "cap") {
+				res := newLinear(zero)
+				res.terms[term{v.Call.Args[0], builtin.Name()}] = one
+				return res
+			}
+		}
+	}
+	res := newLinear(zero)
+	res.terms[term{value: value}] = one
+	return res
+}
+
+// interval returns the range of values of the numeric value.
+//
+// It is derived from the type, branch conditions, len and cap being non-negative,
+// and integer arithmetic.
+func (r resolver) interval(value ssa.Value, block *ssa.BasicBlock, depth int) (interval, bool) {
+	bounds, ok := r.typeBounds(value.Type())
+	if !ok {
+		return interval{}, false
+	}
+	if !isInteger(value.Type()) {
+		// Float arithmetic is not exact, so only conversions are supported.
+		conv, isConv := value.(*ssa.Convert)
+		if isConv && isInteger(conv.X.Type()) && depth < maxDepth {
+			res, _ := r.interval(conv.X, block, depth+1)
+			return r.narrowInterval(res, value, block), true
+		}
+		return r.narrowInterval(bounds, value, block), true
+	}
+	form := r.linear(value, depth)
+	res := interval{form.c, form.c}
+	for t, coef := range form.terms {
+		res = res.add(r.termInterval(t, block, depth).scale(coef))
+	}
+	if !res.within(bounds) { // might overflow
+		res = bounds
+	}
+	return r.narrowInterval(res, value, block), true
+}
+
+// termInterval returns the range of values of an unknown integer value in a linear form.
+func (r resolver) termInterval(t term, block *ssa.BasicBlock, depth int) interval {
+	intBounds, _ := r.typeBounds(types.Typ[types.Int])
+	if t.builtin != "" { // len or cap
+		return interval{constant.MakeInt64(0), intBounds.hi}
+	}
+	bounds, _ := r.typeBounds(t.value.Type())
+	conv, isConv := t.value.(*ssa.Convert)
+	if isConv && isInteger(conv.X.Type()) && depth < maxDepth {
+		res, _ := r.interval(conv.X, block, depth+1)
+		if res.within(bounds) {
+			bounds = res
+		}
+	}
+	return r.narrowInterval(bounds, t.value, block)
+}
+
+// narrowInterval narrows the interval using the branch conditions.
+func (r resolver) narrowInterval(iv interval, value ssa.Value, block *ssa.BasicBlock) interval {
+	exact, rng, ok := r.narrow(value, block)
+	if exact != nil && exact.Kind() == constant.Int {
+		return interval{exact, exact}
+	}
+	if !ok {
+		return iv
+	}
+	// Open bounds of floats are used as closed, which is less precise but still correct.
+	if rng.Min != nil && (iv.lo == nil || constant.Compare(rng.Min, token.GTR, iv.lo)) {
+		iv.lo = rng.Min
+	}
+	if rng.Max != nil && (iv.hi == nil || constant.Compare(rng.Max, token.LSS, iv.hi)) {
+		iv.hi = rng.Max
+	}
+	return iv
+}
+
+// typeBounds returns the range of values that the numeric type can represent.
+//
+// Floats are unbounded. Complex numbers and non-numeric types are not supported.
+func (r resolver) typeBounds(t types.Type) (interval, bool) {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return interval{}, false
+	}
+	info := basic.Info()
+	if info&types.IsFloat != 0 {
+		return interval{}, true
+	}
+	if info&types.IsInteger == 0 || info&types.IsUntyped != 0 {
+		return interval{}, false
+	}
+	bits := uint(r.sizes.Sizeof(t) * 8)
+	one := constant.MakeInt64(1)
+	if info&types.IsUnsigned != 0 {
+		max := constant.Shift(one, token.SHL, bits)
+		return interval{constant.MakeInt64(0), constant.BinaryOp(max, token.SUB, one)}, true
+	}
+	max := constant.Shift(one, token.SHL, bits-1)
+	return interval{constant.UnaryOp(token.SUB, max, 0), constant.BinaryOp(max, token.SUB, one)}, true
+}
+
+// argRange returns what is known about the argument value in the block.
+//
+// If the exact value is known, it is returned as the first result value.
+// Otherwise, the second one is the range of values if anything is known about it.
+func (r resolver) argRange(value ssa.Value, block *ssa.BasicBlock) (constant.Value, contracts.Range, bool) {
+	exact, rng, narrowed := r.narrow(value, block)
+	if exact != nil {
+		return exact, rng, false
+	}
+	iv, ok := r.interval(value, block, 0)
+	if !ok {
+		return nil, rng, narrowed
+	}
+	bounds, _ := r.typeBounds(value.Type())
+	if bounds.within(iv) { // nothing new besides what the type says
+		return nil, rng, narrowed
+	}
+	if iv.lo != nil && iv.hi != nil && constant.Compare(iv.lo, token.EQL, iv.hi) {
+		return iv.lo, rng, false
+	}
+	if iv.lo != nil {
+		rng = rng.Constrain(token.GEQ, iv.lo)
+	}
+	if iv.hi != nil {
+		rng = rng.Constrain(token.LEQ, iv.hi)
+	}
+	return nil, rng, true
+}
+
+func isInteger(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
@@ -0,0 +1,147 @@
+package arguard
+
+import (
+	"go/constant"
+	"go/token"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// loop is a loop with a statically known list of iterations.
+type loop struct {
+	header *ssa.BasicBlock
+	// the loop variable, like i in `for i := 0; i < 3; i++`
+	// or the index of the current element for range loops
+	phi *ssa.Phi
+	// values of the loop variable on each iteration
+	values []constant.Value
+}
+
+// findLoop finds the innermost loop containing the block
+// if it has at most max iterations that can be statically calculated.
+//
+// Loops that can be exited not only through the loop condition,
+// like with break, return, or panic, are not supported because the number
+// of iterations depends on something else.
+func (r resolver) findLoop(block *ssa.BasicBlock, max int) (*loop, bool) {
+	for header := block; header != nil; header = header.Idom() {
+		latch := -1
+		for i, pred := range header.Preds {
+			if header.Dominates(pred) {
+				if latch != -1 {
+					return nil, false
+				}
+				latch = i
+			}
+		}
+		if latch == -1 {
+			continue
+		}
+		body := loopBlocks(header, header.Preds[latch])
+		if _, found := body[block]; !found {
+			continue
+		}
+		// the loop is innermost, so it must be unrolled or not checked at all
+		nIf, ok := lastInstr(header).(*ssa.If)
+		if !ok {
+			return nil, false
+		}
+		if _, found := body[header.Succs[0]]; !found {
+			return nil, false
+		}
+		for b := range body {
+			for _, succ := range b.Succs {
+				_, found := body[succ]
+				if !found && b != header {
+					return nil, false
+				}
+			}
+		}
+		for _, instr := range header.Instrs {
+			phi, ok := instr.(*ssa.Phi)
+			if !ok {
+				break
+			}
+			values, ok := r.iterate(phi, latch, nIf.Cond, max)
+			if ok {
+				return &loop{header: header, phi: phi, values: values}, true
+			}
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// iterate calculates values of the phi node on each iteration of the loop.
+func (r resolver) iterate(phi *ssa.Phi, latch int, cond ssa.Value, max int) ([]constant.Value, bool) {
+	defer delete(r.fixed, phi)
+	// the value before the loop must be the same for all entries
+	var val constant.Value
+	for i, edge := range phi.Edges {
+		if i == latch {
+			continue
+		}
+		init, ok := r.resolve(edge)
+		if !ok || (val != nil && !constant.Compare(val, token.EQL, init)) {
+			return nil, false
+		}
+		val = init
+	}
+	values := make([]constant.Value, 0)
+	for len(values) <= max {
+		r.fixed[phi] = val
+		ok, known := r.resolve(cond)
+		if !known || ok.Kind() != constant.Bool {
+			return nil, false
+		}
+		if !constant.BoolVal(ok) {
+			return values, true
+		}
+		values = append(values, val)
+		val, known = r.resolve(phi.Edges[latch])
+		if !known {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// reachable checks that the block in the loop might be reached
+// on the current iteration, according to the conditions of the branches
+// inside of the loop that lead to it.
+func (r resolver) reachable(block *ssa.BasicBlock, l *loop) bool {
+	for child := block; child != l.header; child = child.Idom() {
+		parent := child.Idom()
+		nIf, ok := lastInstr(parent).(*ssa.If)
+		if !ok {
+			continue
+		}
+		cond, known := r.resolve(nIf.Cond)
+		if !known || cond.Kind() != constant.Bool {
+			continue
+		}
+		for i, succ := range parent.Succs {
+			if len(succ.Preds) == 1 && succ.Dominates(block) && constant.BoolVal(cond) != (i == 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// loopBlocks returns all blocks of the loop with the given header
+// and the block jumping back into it.
+func loopBlocks(header, latch *ssa.BasicBlock) map[*ssa.BasicBlock]struct{} {
+	blocks := map[*ssa.BasicBlock]struct{}{header: {}}
+	queue := []*ssa.BasicBlock{latch}
+	for len(queue) > 0 {
+		block := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if _, found := blocks[block]; found {
+			continue
+		}
+		blocks[block] = struct{}{}
+		queue = append(queue, block.Preds...)
+	}
+	return blocks
+}
@@ -0,0 +1,115 @@
+package arguard
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/orsinium-labs/arguard/contracts"
+	"golang.org/x/tools/go/ssa"
+)
+
+// narrow finds what is known about the value in the block
+// from conditions of all branches that lead to the block.
+//
+// For example, inside of `if n == 0 { ... }` and after `if n != 0 { return }`
+// the value of n is known to be zero.
+//
+// It returns the exact value if it's known. Otherwise, it returns
+// the range of values and if anything is known about the value.
+func (r resolver) narrow(value ssa.Value, block *ssa.BasicBlock) (constant.Value, contracts.Range, bool) {
+	// if the exact value is known before conversion, it's known after it too
+	conv, isConv := value.(*ssa.Convert)
+	if isConv {
+		exact, _, _ := r.narrow(conv.X, block)
+		if exact != nil {
+			exact, ok := r.convert(exact, conv.Type())
+			if ok {
+				return exact, contracts.Range{}, false
+			}
+		}
+	}
+	basic, ok := value.Type().Underlying().(*types.Basic)
+	if !ok {
+		return nil, contracts.Range{}, false
+	}
+	isNumeric := basic.Info()&types.IsNumeric != 0 && basic.Info()&types.IsComplex == 0
+	rng := contracts.Range{Integer: basic.Info()&types.IsInteger != 0}
+	narrowed := false
+	var exact constant.Value
+	for dom := block.Idom(); dom != nil; dom = dom.Idom() {
+		nIf, ok := lastInstr(dom).(*ssa.If)
+		if !ok {
+			continue
+		}
+		for i, succ := range dom.Succs {
+			// the condition is known only if the block
+			// can be reached only through this branch
+			if len(succ.Preds) != 1 || !succ.Dominates(block) {
+				continue
+			}
+			op, bound, ok := r.comparison(nIf.Cond, value, i == 0)
+			if !ok {
+				continue
+			}
+			switch {
+			case op == token.EQL:
+				exact = bound
+			case isNumeric:
+				rng = rng.Constrain(op, bound)
+				narrowed = true
+			}
+		}
+	}
+	return exact, rng, narrowed
+}
+
+// comparison converts the condition into `value op bound` form,
+// given that the condition is known to be true or false.
+func (r resolver) comparison(cond ssa.Value, value ssa.Value, truth bool) (token.Token, constant.Value, bool) {
+	switch v := cond.(type) {
+	case *ssa.UnOp:
+		if v.Op == token.NOT {
+			return r.comparison(v.X, value, !truth)
+		}
+	case *ssa.BinOp:
+		op := v.Op
+		other := v.Y
+		if v.X != value {
+			if v.Y != value {
+				return op, nil, false
+			}
+			other = v.X
+			op = contracts.Mirror(op)
+		}
+		bound, ok := r.resolve(other)
+		if !ok {
+			return op, nil, false
+		}
+		if !truth {
+			// for floats, comparisons with NaN are always false,
+			// so `!(x < 0)` doesn't mean that `x >= 0`
+			if isFloat(value.Type()) && op != token.EQL && op != token.NEQ {
+				return op, nil, false
+			}
+			op = contracts.Invert(op)
+		}
+		switch op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return op, bound, true
+		}
+	}
+	return token.ILLEGAL, nil, false
+}
+
+func lastInstr(block *ssa.BasicBlock) ssa.Instruction {
+	if len(block.Instrs) == 0 {
+		return nil
+	}
+	return block.Instrs[len(block.Instrs)-1]
+}
+
+func isFloat(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsFloat != 0
+}
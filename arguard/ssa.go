@@ -0,0 +1,450 @@
+package arguard
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+
+	"github.com/orsinium-labs/arguard/contracts"
+	"golang.org/x/tools/go/ssa"
+)
+
+// maxElements is the maximum length of literals which elements are resolved.
+const maxElements = 1024
+
+// findCalls maps positions of calls (opening parenthesis) to their SSA representation.
+func findCalls(funcs []*ssa.Function) map[token.Pos]ssa.CallInstruction {
+	res := make(map[token.Pos]ssa.CallInstruction)
+	var visit func(fn *ssa.Function)
+	visit = func(fn *ssa.Function) {
+		for _, block := range fn.Blocks {
+			for _, instr := range block.Instrs {
+				call, ok := instr.(ssa.CallInstruction)
+				if ok && call.Common().Pos().IsValid() {
+					res[call.Common().Pos()] = call
+				}
+			}
+		}
+		for _, anon := range fn.AnonFuncs {
+			visit(anon)
+		}
+	}
+	for _, fn := range funcs {
+		visit(fn)
+	}
+	return res
+}
+
+// resolver finds statically known values of SSA values.
+type resolver struct {
+	sizes types.Sizes
+	// phi nodes being resolved, to not loop forever on cycles
+	visiting map[*ssa.Phi]struct{}
+	// values assumed to be known, like the loop variable on the current iteration
+	fixed map[ssa.Value]constant.Value
+}
+
+func newResolver(sizes types.Sizes) resolver {
+	return resolver{
+		sizes:    sizes,
+		visiting: make(map[*ssa.Phi]struct{}),
+		fixed:    make(map[ssa.Value]constant.Value),
+	}
+}
+
+// resolveArg returns a valid Go expression for the value if it's statically known.
+func (r resolver) resolveArg(value ssa.Value) (string, bool) {
+	c, ok := value.(*ssa.Const)
+	if ok && c.Value == nil && c.IsNil() {
+		return "nil", true
+	}
+	val, ok := r.resolve(value)
+	if !ok {
+		return "", false
+	}
+	return contracts.FormatConstant(val), true
+}
+
+// resolve returns the constant value of the SSA value if it's statically known.
+//
+// It traces values through phi nodes if all edges have the same value,
+// arithmetic operations, conversions, and elements of literals.
+// The values are calculated the same way as in runtime, and if the result
+// overflows the type, the value is considered unknown.
+func (r resolver) resolve(value ssa.Value) (constant.Value, bool) {
+	if val, found := r.fixed[value]; found {
+		return val, true
+	}
+	switch v := value.(type) {
+	case *ssa.Const:
+		if v.Value == nil {
+			return nil, false
+		}
+		return r.fit(v.Value, v.Type())
+	case *ssa.Phi:
+		return r.resolvePhi(v)
+	case *ssa.ChangeType:
+		return r.resolve(v.X)
+	case *ssa.Convert:
+		x, ok := r.resolve(v.X)
+		if !ok {
+			return nil, false
+		}
+		return r.convert(x, v.Type())
+	case *ssa.UnOp:
+		if v.Op == token.MUL {
+			addr, ok := v.X.(*ssa.IndexAddr)
+			if !ok {
+				return nil, false
+			}
+			return r.resolveElement(addr.X, addr.Index)
+		}
+		return r.resolveUnOp(v)
+	case *ssa.BinOp:
+		return r.resolveBinOp(v)
+	case *ssa.Index:
+		return r.resolveElement(v.X, v.Index)
+	case *ssa.Call:
+		builtin, ok := v.Call.Value.(*ssa.Builtin)
+		if !ok || (builtin.Name() != "len" && builtin.Name() != "cap") {
+			return nil, false
+		}
+		elems, ok := r.elements(v.Call.Args[0])
+		if !ok {
+			return nil, false
+		}
+		return constant.MakeInt64(int64(len(elems))), true
+	}
+	return nil, false
+}
+
+func (r resolver) resolveElement(coll, index ssa.Value) (constant.Value, bool) {
+	elems, ok := r.elements(coll)
+	if !ok {
+		return nil, false
+	}
+	idx, ok := r.resolve(index)
+	if !ok {
+		return nil, false
+	}
+	i, ok := constant.Int64Val(idx)
+	if !ok || i < 0 || i >= int64(len(elems)) {
+		return nil, false
+	}
+	return elems[i], true
+}
+
+// elements returns values of all elements of a slice or an array
+// that is created from a literal and never modified, like `[]int{1, 2, 3}`.
+func (r resolver) elements(value ssa.Value) ([]constant.Value, bool) {
+	var alloc *ssa.Alloc
+	switch v := value.(type) {
+	case *ssa.Slice:
+		if v.Low != nil || v.High != nil || v.Max != nil {
+			return nil, false
+		}
+		alloc, _ = v.X.(*ssa.Alloc)
+	case *ssa.UnOp:
+		if v.Op == token.MUL {
+			alloc, _ = v.X.(*ssa.Alloc)
+		}
+	}
+	if alloc == nil {
+		return nil, false
+	}
+	arr, ok := alloc.Type().(*types.Pointer).Elem().Underlying().(*types.Array)
+	if !ok || arr.Len() > maxElements {
+		return nil, false
+	}
+	elems := make([]constant.Value, arr.Len())
+	block := alloc.Block()
+	// the position of the last store in the block, reads must be after it
+	last := -1
+	for _, ref := range *alloc.Referrers() {
+		addr, ok := ref.(*ssa.IndexAddr)
+		if !ok {
+			continue
+		}
+		idx, ok := r.resolve(addr.Index)
+		if !ok {
+			return nil, false
+		}
+		i, ok := constant.Int64Val(idx)
+		if !ok || i < 0 || i >= int64(len(elems)) || elems[i] != nil {
+			return nil, false
+		}
+		// the element must be only set right after the allocation
+		for _, ref := range *addr.Referrers() {
+			store, ok := ref.(*ssa.Store)
+			if !ok || store.Addr != addr || store.Block() != block || elems[i] != nil {
+				return nil, false
+			}
+			val, ok := r.resolve(store.Val)
+			if !ok {
+				return nil, false
+			}
+			elems[i] = val
+			if pos := indexOf(block, store); pos > last {
+				last = pos
+			}
+		}
+	}
+	for i, elem := range elems {
+		if elem == nil {
+			elems[i] = zeroValue(arr.Elem())
+			if elems[i] == nil {
+				return nil, false
+			}
+		}
+	}
+	for _, ref := range *alloc.Referrers() {
+		switch ref := ref.(type) {
+		case *ssa.IndexAddr, *ssa.DebugRef:
+			continue
+		case *ssa.Slice:
+			if !readOnly(ref) {
+				return nil, false
+			}
+		case *ssa.UnOp: // copying the array
+			if ref.Op != token.MUL {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+		if ref.Block() == block && indexOf(block, ref) < last {
+			return nil, false
+		}
+	}
+	return elems, true
+}
+
+// readOnly checks that the slice is used only to read elements and length.
+func readOnly(slice *ssa.Slice) bool {
+	for _, ref := range *slice.Referrers() {
+		switch ref := ref.(type) {
+		case *ssa.IndexAddr:
+			for _, ref := range *ref.Referrers() {
+				load, ok := ref.(*ssa.UnOp)
+				if !ok || load.Op != token.MUL {
+					return false
+				}
+			}
+		case *ssa.Call:
+			builtin, ok := ref.Call.Value.(*ssa.Builtin)
+			if !ok || (builtin.Name() != "len" && builtin.Name() != "cap") {
+				return false
+			}
+		case *ssa.DebugRef:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func indexOf(block *ssa.BasicBlock, instr ssa.Instruction) int {
+	for i, other := range block.Instrs {
+		if other == instr {
+			return i
+		}
+	}
+	return -1
+}
+
+// zeroValue returns the zero value of the basic type.
+func zeroValue(t types.Type) constant.Value {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return nil
+	}
+	info := basic.Info()
+	switch {
+	case info&types.IsInteger != 0:
+		return constant.MakeInt64(0)
+	case info&types.IsFloat != 0:
+		return constant.MakeFloat64(0)
+	case info&types.IsString != 0:
+		return constant.MakeString("")
+	case info&types.IsBoolean != 0:
+		return constant.MakeBool(false)
+	}
+	return nil
+}
+
+func (r resolver) resolvePhi(phi *ssa.Phi) (constant.Value, bool) {
+	if _, found := r.visiting[phi]; found {
+		return nil, false
+	}
+	r.visiting[phi] = struct{}{}
+	defer delete(r.visiting, phi)
+	var res constant.Value
+	for _, edge := range phi.Edges {
+		val, ok := r.resolve(edge)
+		if !ok {
+			return nil, false
+		}
+		if res != nil && !constant.Compare(res, token.EQL, val) {
+			return nil, false
+		}
+		res = val
+	}
+	return res, res != nil
+}
+
+func (r resolver) resolveUnOp(op *ssa.UnOp) (constant.Value, bool) {
+	x, ok := r.resolve(op.X)
+	if !ok {
+		return nil, false
+	}
+	var prec uint
+	switch op.Op {
+	case token.SUB, token.NOT:
+	case token.XOR:
+		// for unsigned integers, ^x depends on the size of the type
+		if isUnsigned(op.Type()) {
+			prec = uint(r.sizes.Sizeof(op.Type()) * 8)
+		}
+	default: // pointer dereference or channel receive
+		return nil, false
+	}
+	return r.fit(constant.UnaryOp(op.Op, x, prec), op.Type())
+}
+
+func (r resolver) resolveBinOp(op *ssa.BinOp) (constant.Value, bool) {
+	x, ok := r.resolve(op.X)
+	if !ok {
+		return nil, false
+	}
+	y, ok := r.resolve(op.Y)
+	if !ok {
+		return nil, false
+	}
+	switch op.Op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return constant.MakeBool(constant.Compare(x, op.Op, y)), true
+	case token.SHL, token.SHR:
+		shift, ok := constant.Uint64Val(y)
+		if !ok || shift > 64 {
+			return nil, false
+		}
+		return r.fit(constant.Shift(x, op.Op, uint(shift)), op.Type())
+	case token.QUO, token.REM:
+		// division by zero panics in runtime or produces infinity
+		if constant.Sign(y) == 0 {
+			return nil, false
+		}
+		if op.Op == token.QUO && x.Kind() == constant.Int && y.Kind() == constant.Int {
+			// integer division, truncated towards zero
+			return r.fit(constant.BinaryOp(x, token.QUO_ASSIGN, y), op.Type())
+		}
+	}
+	return r.fit(constant.BinaryOp(x, op.Op, y), op.Type())
+}
+
+// convert converts the constant into the given type the same way as in runtime.
+func (r resolver) convert(x constant.Value, t types.Type) (constant.Value, bool) {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return nil, false
+	}
+	info := basic.Info()
+	switch {
+	case info&types.IsInteger != 0:
+		if x.Kind() == constant.Float {
+			// conversion from float to integer truncates the fractional part
+			f, _ := constant.Float64Val(x)
+			f = math.Trunc(f)
+			if math.IsInf(f, 0) || math.IsNaN(f) || math.Abs(f) > math.MaxInt64 {
+				return nil, false
+			}
+			x = constant.MakeInt64(int64(f))
+		}
+		if x.Kind() != constant.Int {
+			return nil, false
+		}
+	case info&types.IsFloat != 0:
+		x = constant.ToFloat(x)
+		if x.Kind() != constant.Float && x.Kind() != constant.Int {
+			return nil, false
+		}
+	case info&(types.IsBoolean|types.IsString) != 0:
+		// integer to string conversions produce runes, not supported
+		if x.Kind() != constant.Bool && x.Kind() != constant.String {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return r.fit(x, t)
+}
+
+// fit checks that the value fits into the type without overflowing.
+//
+// Floats are rounded to the precision of the type because constant
+// arithmetic is exact, unlike the arithmetic at runtime.
+func (r resolver) fit(x constant.Value, t types.Type) (constant.Value, bool) {
+	if x.Kind() == constant.Unknown {
+		return nil, false
+	}
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok || basic.Info()&types.IsUntyped != 0 {
+		return x, true
+	}
+	switch basic.Kind() {
+	case types.Float32, types.Complex64:
+		return roundFloat(x, 32)
+	case types.Float64, types.Complex128:
+		return roundFloat(x, 64)
+	}
+	if basic.Info()&types.IsInteger == 0 {
+		return x, true
+	}
+	bits := uint(r.sizes.Sizeof(t) * 8)
+	min := constant.MakeInt64(0)
+	max := constant.Shift(constant.MakeInt64(1), token.SHL, bits)
+	if !isUnsigned(t) {
+		max = constant.Shift(constant.MakeInt64(1), token.SHL, bits-1)
+		min = constant.UnaryOp(token.SUB, max, 0)
+	}
+	if constant.Compare(x, token.LSS, min) || constant.Compare(x, token.GEQ, max) {
+		return nil, false
+	}
+	return x, true
+}
+
+// roundFloat rounds the real and imaginary parts of the number
+// to the float of the given size.
+func roundFloat(x constant.Value, bits int) (constant.Value, bool) {
+	round := func(part constant.Value) (constant.Value, bool) {
+		var f float64
+		if bits == 32 {
+			f32, _ := constant.Float32Val(part)
+			f = float64(f32)
+		} else {
+			f, _ = constant.Float64Val(part)
+		}
+		if math.IsInf(f, 0) {
+			return nil, false
+		}
+		return constant.MakeFloat64(f), true
+	}
+	if x.Kind() != constant.Complex {
+		return round(x)
+	}
+	re, ok := round(constant.Real(x))
+	if !ok {
+		return nil, false
+	}
+	im, ok := round(constant.Imag(x))
+	if !ok {
+		return nil, false
+	}
+	return constant.BinaryOp(re, token.ADD, constant.MakeImag(im)), true
+}
+
+func isUnsigned(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsUnsigned != 0
+}
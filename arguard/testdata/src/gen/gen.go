@@ -0,0 +1,11 @@
+package gen
+
+func Check(x int) {
+	if x < 0 {
+		panic("negative")
+	}
+}
+
+func F1() {
+	Check(-1) // want "contract violated: negative"
+}
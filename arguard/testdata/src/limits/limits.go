@@ -0,0 +1,22 @@
+package limits
+
+import "errors"
+
+const (
+	MaxRetries = 5
+	Half       = 0.5
+)
+
+func Check(retries int) {
+	if retries > MaxRetries {
+		panic("too many retries")
+	}
+}
+
+func CheckAll(retries, delay int) error {
+	Check(retries)
+	if delay < 0 {
+		return errors.New("negative delay")
+	}
+	return nil
+}
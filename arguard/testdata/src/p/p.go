@@ -1,5 +1,15 @@
 package p
 
+import (
+	"errors"
+	"fmt"
+	"limits"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+)
+
 const FIVE = 5
 
 func F1(in int) {
@@ -42,3 +52,689 @@ func F4(in int) {
 	F3(3+2, in)      // want "contract violated: x is five"
 	F3(FIVE-1, in)
 }
+
+type Duration int64
+
+func F5(x int64, d Duration) {
+	if -x > 0 {
+		panic("x is negative")
+	}
+	if (d + 1) == 1 {
+		panic("d is zero")
+	}
+}
+
+func F6(in int64, d Duration) {
+	F5(1, 1)
+	F5(-1, 1) // want "contract violated: x is negative"
+	F5(-(-1), 1)
+	F5(int64(-2), 1)    // want "contract violated: x is negative"
+	F5(in, Duration(0)) // want "contract violated: d is zero"
+	F5(in, d)
+}
+
+func F7(n int, f float64) {
+	if n > limits.MaxRetries {
+		panic("too many retries")
+	}
+	if f == limits.Half {
+		panic("f is half")
+	}
+}
+
+func F8(in int) {
+	F7(limits.MaxRetries, 0)
+	F7(limits.MaxRetries+1, 0) // want "contract violated: too many retries"
+	F7(in, limits.Half)        // want "contract violated: f is half"
+	F7(in, 1/limits.Half/4)    // want "contract violated: f is half"
+	F7(in, 1/2)
+}
+
+func F9(d float64, u uint8) {
+	if d/2 == 0 {
+		panic("d is zero")
+	}
+	if u+10 < 10 {
+		panic("u overflows")
+	}
+}
+
+func F10() {
+	F9(1, 0)
+	F9(0, 0)   // want "contract violated: d is zero"
+	F9(1, 250) // want "contract violated: u overflows"
+}
+
+func Sum(n int, xs ...int) {
+	if n < 0 {
+		panic("negative n")
+	}
+	if len(xs) == 0 {
+		panic("nothing to sum")
+	}
+}
+
+func Clamp[T ~int | ~float64](x T) {
+	if x < 0 {
+		panic("negative x")
+	}
+}
+
+func F11() {
+	Sum(-1, 5) // want "contract violated: negative n"
+	Sum(1, 5)
+	Clamp(-1) // want "contract violated: negative x"
+	Clamp(2.5)
+	Clamp[float64](-0.5) // want "contract violated: negative x"
+}
+
+type Point struct{ X, Y int }
+
+type Handler func(int) error
+
+func F12(p *Point, s []int, m map[string]int, c chan int, f Handler, i interface{}) {
+	if p == nil {
+		panic("p is nil")
+	}
+	if s == nil {
+		panic("s is nil")
+	}
+	if m == nil {
+		panic("m is nil")
+	}
+	if c == nil {
+		panic("c is nil")
+	}
+	if f == nil {
+		panic("f is nil")
+	}
+	if i == nil {
+		panic("i is nil")
+	}
+}
+
+func F13(p *Point, s []int, m map[string]int, c chan int, f Handler, i interface{}) {
+	F12(p, s, m, c, f, i)
+	F12(nil, s, m, c, f, i)           // want "contract violated: p is nil"
+	F12(p, nil, m, c, f, i)           // want "contract violated: s is nil"
+	F12(p, s, nil, c, f, i)           // want "contract violated: m is nil"
+	F12(p, s, m, nil, f, i)           // want "contract violated: c is nil"
+	F12(p, s, m, c, nil, i)           // want "contract violated: f is nil"
+	F12(p, s, m, c, f, nil)           // want "contract violated: i is nil"
+	F12((*Point)(nil), s, m, c, f, i) // want "contract violated: p is nil"
+}
+
+func Process(items []int) {
+	if len(items) == 0 {
+		panic("no items")
+	}
+}
+
+func Get(items []int, i int) int {
+	if i >= len(items) {
+		panic("index out of range")
+	}
+	return items[i]
+}
+
+func Lookup(m map[string]int, key string) {
+	if len(m) == 0 {
+		panic("empty map")
+	}
+	if len(key) == 0 {
+		panic("empty key")
+	}
+}
+
+func F14(items []int, key string) {
+	Process(items)
+	Process([]int{1})
+	Process([]int{})         // want "contract violated: no items"
+	Process([]int{1, 2}[:0]) // not supported
+	Get(items, 3)
+	Get([]int{1, 2, 3}, 2)
+	Get([]int{1, 2, 3}, 3)         // want "contract violated: index out of range"
+	Get([]int{2: 1}, 3)            // want "contract violated: index out of range"
+	Get([][2]int{{1, 2}}[0][:], 1) // not supported
+	Lookup(map[string]int{"a": 1}, key)
+	Lookup(map[string]int{}, key)      // want "contract violated: empty map"
+	Lookup(nil, key)                   // want "contract violated: empty map"
+	Lookup(nil, "")                    // want "contract violated: empty map"
+	Lookup(map[string]int{"a": 1}, "") // want "contract violated: empty key"
+}
+
+func Open(path string, x float64, r rune) {
+	if !strings.HasPrefix(path, "/") {
+		panic("path must be absolute")
+	}
+	if math.IsNaN(x) {
+		panic("x is NaN")
+	}
+	if unicode.IsUpper(r) {
+		panic("r must not be uppercase")
+	}
+}
+
+func F15(path string, x float64) {
+	Open("/tmp", 1, 'a')
+	Open("tmp", 1, 'a') // want "contract violated: path must be absolute"
+	Open(path, math.NaN(), 'a')
+	Open(path, x, 'A') // want "contract violated: r must not be uppercase"
+}
+
+func div(n, d float64) (float64, error) {
+	if d == 0 {
+		return 0, fmt.Errorf("denominator must not be zero, got %v", d)
+	}
+	if n < 0 {
+		panic(fmt.Sprintf("numerator must not be negative, got %.1f (denominator is %v)", n, d))
+	}
+	if n == 42 {
+		panic(errors.New("n is 42"))
+	}
+	return n / d, nil
+}
+
+func F16(x float64) {
+	_, _ = div(1, 0)  // want "contract violated: denominator must not be zero, got 0"
+	_, _ = div(-2, x) // want `contract violated: numerator must not be negative, got -2.0 \(denominator is d\)`
+	_, _ = div(42, x) // want "contract violated: n is 42"
+}
+
+var ErrInvalidPort = errors.New("port must be non-zero")
+
+func Listen(port int) error {
+	if port == 0 {
+		return ErrInvalidPort
+	}
+	return nil
+}
+
+func F17() {
+	_ = Listen(0) // want `contract violated: returns ErrInvalidPort \("port must be non-zero"\)`
+}
+
+func SetX(x int) error {
+	if x < 0 {
+		log.Printf("bad x %d", x)
+		return fmt.Errorf("x must not be negative, got %d", x)
+	}
+	return nil
+}
+
+func F18() {
+	_ = SetX(1)
+	_ = SetX(-1) // want "contract violated: x must not be negative, got -1"
+}
+
+var modes = map[string]int{"fast": 1, "slow": 2}
+
+func Run(mode string, s string) {
+	if _, ok := modes[mode]; !ok {
+		panic("unknown mode")
+	}
+	if n := len(s); n > 3 {
+		panic(fmt.Sprintf("name is too long: %d", n))
+	}
+}
+
+func F19(s string) {
+	Run("fast", s)
+	Run("typo", s) // want "contract violated: unknown mode"
+	Run("slow", "abc")
+	Run("slow", "abcd") // want "contract violated: name is too long: 4"
+}
+
+func Percent(n int) {
+	switch {
+	case n < 0:
+		panic("negative")
+	case n > 100:
+		panic("too big")
+	case n > 50:
+		panic("more than half")
+	}
+}
+
+func Level(n int) {
+	if n == 0 {
+		panic("zero")
+	} else if n < 10 {
+		panic("small")
+	}
+}
+
+func F20() {
+	Percent(-1)  // want "contract violated: negative"
+	Percent(101) // want "contract violated: too big"
+	Percent(51)  // want "contract violated: more than half"
+	Percent(50)
+	Level(0) // want "contract violated: zero"
+	Level(5) // want "contract violated: small"
+	Level(10)
+}
+
+type Mode int
+
+const (
+	ModeA Mode = iota + 1
+	ModeB
+	ModeC
+)
+
+func Start(mode Mode) {
+	switch mode {
+	case ModeA, ModeB:
+	case ModeC:
+		log.Println("mode C is deprecated")
+	default:
+		panic(fmt.Sprintf("unknown mode %d", mode))
+	}
+}
+
+func Encode(format string) {
+	switch format {
+	case "json":
+	case "xml":
+		panic("xml is not supported")
+	default:
+		panic("unknown format")
+	}
+}
+
+func F21() {
+	Start(ModeA)
+	Start(ModeC)
+	Start(Mode(7)) // want `contract violated: unknown mode 7 \(accepted values: ModeA, ModeB, ModeC\)`
+	Encode("json")
+	Encode("xml")  // want "contract violated: xml is not supported"
+	Encode("typo") // want `contract violated: unknown format \(accepted values: "json"\)`
+}
+
+func Resize(width, height int) {
+	log.Println("resize")
+	if height < 0 {
+		height = 0
+	}
+	if width < 0 {
+		panic("negative width")
+	}
+	if height > 100 {
+		panic("too high")
+	}
+}
+
+func F22() {
+	Resize(-1, 10) // want "contract violated: negative width"
+	Resize(1, 101)
+}
+
+func checkSize(w, h int) {
+	if w <= 0 {
+		panic("width must be positive")
+	}
+	if h <= 0 {
+		panic(fmt.Sprintf("height must be positive, got %d", h))
+	}
+}
+
+func validateTitle(title string) error {
+	if len(title) > 5 {
+		return fmt.Errorf("title is too long: %q", title)
+	}
+	return nil
+}
+
+func Scale(h, w int, title string) error {
+	checkSize(w, h)
+	if err := validateTitle(title); err != nil {
+		return err
+	}
+	return nil
+}
+
+func F23() {
+	_ = Scale(5, 0, "")       // want `contract violated: width must be positive \(via checkSize\)`
+	_ = Scale(-1, 5, "")      // want `contract violated: height must be positive, got -1 \(via checkSize\)`
+	_ = Scale(1, 1, "abcdef") // want `contract violated: title is too long: "abcdef" \(via validateTitle\)`
+	_ = Scale(1, 1, "abc")
+}
+
+func checkNil(v any) {
+	if v == nil {
+		panic("nil value")
+	}
+}
+
+func WrapPtr(p *int) {
+	checkNil(p)
+}
+
+func WrapAny(v any) {
+	checkNil(v)
+}
+
+func F24() {
+	WrapPtr(nil)
+	WrapAny(nil) // want `contract violated: nil value \(via checkNil\)`
+}
+
+func Parse(s string, base int) (int, error) {
+	if s == "" {
+		return 0, errors.New("empty input")
+	}
+	if base < 2 || base > 36 {
+		panic(fmt.Sprintf("invalid base %d", base))
+	}
+	return len(s), nil
+}
+
+func ParseDecimal(s string) (int, error) {
+	return Parse(s, 10)
+}
+
+func MustParse(s string) int {
+	n, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func ParseBase(base int, s string) int {
+	n, _ := Parse(s, base)
+	return n
+}
+
+func F25() {
+	_, _ = ParseDecimal("") // want `contract violated: empty input \(via Parse\)`
+	_ = MustParse("")       // want `contract violated: empty input \(via ParseDecimal -> Parse\)`
+	_ = MustParse("12")
+	_ = ParseBase(1, "12") // want `contract violated: invalid base 1 \(via Parse\)`
+	_ = ParseBase(10, "")
+}
+
+func u(n int, x uint8) {
+	if n > 0 && x+10 > 200 {
+		panic("big")
+	}
+}
+
+func wrapU(n int) {
+	u(n, 250)
+}
+
+func F26() {
+	u(1, 250)
+	wrapU(1)
+}
+
+var ErrZero = errors.New("division by zero")
+
+func Div(a, d int) (int, error) {
+	if d == 0 {
+		return 0, ErrZero
+	}
+	return a / d, nil
+}
+
+func Must(d int) int {
+	v, err := Div(1, d)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func M2(d int) {
+	Must(d)
+}
+
+func F27() {
+	Must(0) // want `contract violated: panics with ErrZero \("division by zero"\) \(via Div\)`
+	M2(0)   // want `contract violated: panics with ErrZero \("division by zero"\) \(via Must -> Div\)`
+	M2(1)
+}
+
+func Retry(delay, retries int) error {
+	return limits.CheckAll(retries, delay)
+}
+
+func F28() {
+	limits.Check(5)
+	limits.Check(6)           // want "contract violated: too many retries"
+	_ = limits.CheckAll(6, 0) // want `contract violated: too many retries \(via Check\)`
+	_ = Retry(-1, 1)          // want `contract violated: negative delay \(via CheckAll\)`
+	_ = Retry(1, 7)           // want `contract violated: too many retries \(via CheckAll -> Check\)`
+}
+
+func F29(flag bool, n int) {
+	x := 0.
+	div(1, x) // want "contract violated: denominator must not be zero"
+	y := 2.
+	if flag {
+		y = 0
+	}
+	div(1, y)
+	z := 0.
+	if flag {
+		z = 3 - 3
+	}
+	div(1, z) // want "contract violated: denominator must not be zero"
+	var half = 1 / 2
+	div(2, float64(half)) // want "contract violated: denominator must not be zero"
+	var big int8 = 100
+	big += 100 // overflows, the value is not known
+	Level(int(big))
+	w := -4
+	Level(w + 4)       // want "contract violated: zero"
+	Level((w + 4) / 2) // want "contract violated: zero"
+	Level(n)
+	for i := 0; i < n; i++ {
+		Level(i)
+	}
+}
+
+func ff(y float64) {
+	if y == 1 {
+		panic("one")
+	}
+}
+
+func F30() {
+	x := 1e16
+	y := x + 1 - 1e16
+	ff(y)
+	z := x - 1e16 + 1
+	ff(z)       // want `contract violated: one`
+	defer ff(z) // want `contract violated: one`
+	go ff(z)    // want `contract violated: one`
+}
+
+func Either(x int, y int) {
+	if x == 0 || y < 0 {
+		panic("x is zero or y is negative")
+	}
+	if x > 10 && y > 10 {
+		panic("both are too big")
+	}
+	if !(x < 100 || y < 100) {
+		panic("both are huge")
+	}
+}
+
+func F31(n int) {
+	Either(n, -1) // want "contract violated: x is zero or y is negative"
+	Either(0, n)  // want "contract violated: x is zero or y is negative"
+	Either(n, 1)
+	Either(n, 11)
+	Either(1, 11)
+	Either(11, 11) // want "contract violated: both are too big"
+	Either(n, 5)
+	Either(n, 200)
+}
+
+func Sqrt(x float64) float64 {
+	if x < 0 {
+		panic("negative number")
+	}
+	return math.Sqrt(x)
+}
+
+func SetSize(size int) {
+	if size <= 0 {
+		panic("size must be positive")
+	}
+	if size > 100 {
+		panic("size is too big")
+	}
+}
+
+func F32(n int, f float64, mode string) {
+	if n == 0 {
+		_, _ = div(1, float64(n)) // want "contract violated: denominator must not be zero"
+		SetSize(n)                // want "contract violated: size must be positive"
+	}
+	if f == 0 {
+		_, _ = div(1, f) // want "contract violated: denominator must not be zero"
+	}
+	if f < 0 {
+		Sqrt(f) // want "contract violated: negative number"
+	} else {
+		Sqrt(f)
+	}
+	if !(f >= 0) {
+		Sqrt(f) // NaN is not negative
+	}
+	if n > 100 {
+		SetSize(n) // want "contract violated: size is too big"
+	}
+	if n < 1 {
+		SetSize(n) // want "contract violated: size must be positive"
+	}
+	if n > 0 && n < 100 {
+		SetSize(n)
+	}
+	if n >= 0 && n != 0 {
+		SetSize(n)
+	}
+	if n >= 0 {
+		if n != 0 {
+			return
+		}
+		SetSize(n) // want "contract violated: size must be positive"
+	}
+	if mode != "typo" {
+		return
+	}
+	Run(mode, "") // want "contract violated: unknown mode"
+}
+
+func NewPool(size int) {
+	if size < 0 {
+		panic("negative pool size")
+	}
+}
+
+func SetPercent(p int) {
+	if p > 100 {
+		panic("percent is too big")
+	}
+}
+
+func SetRatio(r float64) {
+	if r < 0 {
+		panic("negative ratio")
+	}
+}
+
+func F33(workers []string, level uint8, n int, names map[string]int) {
+	NewPool(len(workers) - len(workers) - 1) // want "contract violated: negative pool size"
+	NewPool(len(workers) - 1)
+	NewPool(-1 - len(names))   // want "contract violated: negative pool size"
+	NewPool(-1 - 2*len(names)) // might overflow
+	NewPool(len(workers) + cap(workers))
+	NewPool(int(level))
+	NewPool(int(level) - 256) // want "contract violated: negative pool size"
+	NewPool(int(level) - 255)
+	NewPool(n - n)
+	SetPercent(int(level) + 200) // want "contract violated: percent is too big"
+	SetPercent(int(level) + 100)
+	SetPercent(int(level + 200)) // uint8 overflow
+	SetRatio(float64(len(workers)))
+	SetRatio(float64(int(level) - 300)) // want "contract violated: negative ratio"
+	if n > 10 {
+		SetPercent(n*10 + 1) // might overflow
+	}
+	if n > 10 && n < 1000 {
+		SetPercent(n*10 + 1) // want "contract violated: percent is too big"
+		SetPercent(n - 10)
+		NewPool(-n) // want "contract violated: negative pool size"
+	}
+}
+
+func Third(i int) {
+	if i == 2 {
+		panic("third")
+	}
+}
+
+func F34(n int, names []string) {
+	for i := 0; i < 3; i++ {
+		Third(i) // want `contract violated: third \(when i = 2\)`
+	}
+	for i := 0; i < 2; i++ {
+		Third(i)
+	}
+	for i := 10; i > 0; i -= 4 {
+		Third(i - 4) // want `contract violated: third \(when i - 4 = 2\)`
+	}
+	for i := 0; i < n; i++ {
+		Third(i)
+	}
+	for i := 0; i < 1000; i++ {
+		Third(i) // too many iterations
+	}
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			break
+		}
+		Third(i)
+	}
+	for i := 0; i < 3; i++ {
+		if i != 2 {
+			Third(i)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if i > 1 {
+			Third(i) // want `contract violated: third$`
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			Third(j) // want `contract violated: third \(when j = 2\)`
+		}
+		Third(i) // want `contract violated: third \(when i = 2\)`
+	}
+	for _, v := range []int{1, 3, 2} {
+		Third(v) // want `contract violated: third \(when v = 2\)`
+	}
+	for i, v := range [...]int{1, 3, 5} {
+		Third(v - i) // want `contract violated: third \(when v - i = 2\)`
+	}
+	nums := []int{2, 1}
+	for _, v := range nums {
+		Third(v) // want `contract violated: third \(when v = 2\)`
+	}
+	Third(nums[0])   // want `contract violated: third`
+	Third(len(nums)) // want `contract violated: third`
+	changed := []int{2}
+	changed[0] = 3
+	for _, v := range changed {
+		Third(v)
+	}
+	for _, name := range []string{"typo", "1"} {
+		Run(name, "") // want `contract violated: unknown mode \(when name = "typo"\)`
+	}
+}
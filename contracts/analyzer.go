@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
+	"path/filepath"
 	"reflect"
 	"strings"
 
@@ -22,6 +24,7 @@ func NewAnalyzer(config Config) *analysis.Analyzer {
 		Doc:        "extracts conditions that function arguments must satisfy",
 		Run:        analyzer.run,
 		ResultType: reflect.TypeOf((Result)(nil)),
+		FactTypes:  []analysis.Fact{new(Function), new(AnalyzedPackage)},
 		Flags:      *config.flagSet(),
 	}
 }
@@ -34,19 +37,40 @@ type analyzer struct {
 func (a analyzer) run(pass *analysis.Pass) (any, error) {
 	facts := make(Result)
 
-	// analyze the current package
-	exportFacts(facts, pass.TypesInfo, pass.Files)
-
-	// analyze all imported packages
+	// collect contracts of all imported packages
 	if a.config.FollowImports {
-		analyzeImports(facts, pass)
+		a.importFacts(facts, pass)
+		a.analyzeImports(facts, pass)
+	}
+
+	// Skip filtered out packages but still mark them as analyzed,
+	// so that the dependent packages don't try to analyze them again.
+	if !a.config.Packages.Allows(pass.Pkg.Path()) {
+		pass.ExportPackageFact(&AnalyzedPackage{0})
+		return facts, nil
+	}
+
+	// analyze the current package,
+	// contracts of imported functions can be inherited by wrappers
+	e := a.extractor(pass.TypesInfo, pass.Fset, pass.Files)
+	e.imported = facts
+	local := make(Result)
+	exportFacts(local, e, pass.Files)
+	for obj, fn := range local {
+		pass.ExportObjectFact(obj, fn.exported())
+		facts[obj] = fn
 	}
+	pass.ExportPackageFact(&AnalyzedPackage{len(local)})
 
-	// if in debug mode, report all detected contracts
+	// if in debug mode, report all contracts detected in the current package
 	if a.config.ReportContracts {
-		for _, fInfo := range facts {
+		for _, fInfo := range local {
 			for _, c := range fInfo.Contracts {
-				pass.Reportf(c.Pos, "contract: %s", c.Message)
+				if len(c.Chain) == 0 {
+					pass.Reportf(c.Pos, "contract: %s", c.Message)
+				} else {
+					pass.Reportf(c.Pos, "contract: %s (via %s)", c.Message, c.Trace())
+				}
 			}
 		}
 	}
@@ -54,7 +78,48 @@ func (a analyzer) run(pass *analysis.Pass) (any, error) {
 	return facts, nil
 }
 
-func analyzeImports(facts Result, pass *analysis.Pass) {
+func (a analyzer) extractor(info *types.Info, fset *token.FileSet, files []*ast.File) extractor {
+	sentinels := findSentinels(info, files)
+	return extractor{
+		info:        info,
+		fset:        fset,
+		pure:        toSet(a.config.PureFuncs),
+		terminators: toSet(a.config.Terminators),
+		sentinels:   sentinels,
+		constMaps:   findConstMaps(info, files, sentinels),
+		decls:       findDecls(info, files),
+		known:       make(map[*types.Func]*extracted),
+	}
+}
+
+// importFacts collects contracts of the imported functions exported as facts.
+func (a analyzer) importFacts(facts Result, pass *analysis.Pass) {
+	for _, fact := range pass.AllObjectFacts() {
+		obj, ok := fact.Object.(*types.Func)
+		if !ok || !a.config.Packages.Allows(obj.Pkg().Path()) {
+			continue
+		}
+		fn, ok := fact.Fact.(*Function)
+		if ok {
+			facts[obj] = fn
+		}
+	}
+}
+
+// analyzeImports extracts contracts from the imported packages that weren't
+// analyzed by the driver, and so have no facts. It's slow because it loads
+// and type checks the packages from scratch, but drivers usually analyze all
+// dependencies, so it is a rare fallback. The results are cached.
+func (a analyzer) analyzeImports(facts Result, pass *analysis.Pass) {
+	if len(pass.Files) == 0 {
+		return
+	}
+	// imports are resolved relative to the current package
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name())
+	imported := make(map[string]*types.Package)
+	for _, pkg := range pass.Pkg.Imports() {
+		imported[pkg.Path()] = pkg
+	}
 	analyzedPackages := make(map[string]struct{})
 	for _, file := range pass.Files {
 		for _, nImport := range file.Imports {
@@ -67,23 +132,87 @@ func analyzeImports(facts Result, pass *analysis.Pass) {
 				continue
 			}
 			analyzedPackages[importPath] = struct{}{}
-			pkg, err := loadPackageInfo(importPath)
+			pkg, found := imported[importPath]
+			if !found { // "C" or something
+				continue
+			}
+			if !a.config.Packages.Allows(importPath) {
+				continue
+			}
+			if pass.ImportPackageFact(pkg, new(AnalyzedPackage)) {
+				continue
+			}
+			byName, err := a.loadImport(importPath, dir)
 			if err != nil {
-				pass.Reportf(nImport.Pos(), "load package info: %v", err)
+				pass.Reportf(nImport.Pos(), "%v", err)
 				continue
 			}
-			if pkg.TypesInfo == nil {
-				pass.Reportf(nImport.Pos(), "package loaded without NeedTypesInfo flag")
+			mergeFacts(facts, byName, pkg)
+		}
+	}
+}
+
+// extractImport loads the package and extracts contracts from it.
+//
+// The contracts are mapped to the full names of functions,
+// so that they can be used with any type objects for the package.
+func (a analyzer) extractImport(key cacheKey) (map[string]*Function, error) {
+	loaded, err := a.loadPackageInfo(key.path, key.dir)
+	if err != nil {
+		return nil, fmt.Errorf("load package info: %v", err)
+	}
+	if loaded.TypesInfo == nil {
+		return nil, errors.New("package loaded without NeedTypesInfo flag")
+	}
+	e := a.extractor(loaded.TypesInfo, loaded.Fset, loaded.Syntax)
+	local := make(Result)
+	exportFacts(local, e, loaded.Syntax)
+	byName := make(map[string]*Function)
+	for obj, fn := range local {
+		byName[obj.FullName()] = fn.exported()
+	}
+	return byName, nil
+}
+
+// mergeFacts adds contracts of the package loaded separately from the current pass.
+//
+// The loaded package has its own type objects, so functions are matched by the full name.
+func mergeFacts(facts Result, byName map[string]*Function, pkg *types.Package) {
+	for _, obj := range funcsOf(pkg) {
+		fn, found := byName[obj.FullName()]
+		if found {
+			facts[obj] = fn
+		}
+	}
+}
+
+// funcsOf returns all package-level functions and methods defined in the package.
+func funcsOf(pkg *types.Package) []*types.Func {
+	res := make([]*types.Func, 0)
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			res = append(res, obj)
+		case *types.TypeName:
+			named, ok := obj.Type().(*types.Named)
+			if !ok {
 				continue
 			}
-			exportFacts(facts, pkg.TypesInfo, pkg.Syntax)
+			for i := 0; i < named.NumMethods(); i++ {
+				res = append(res, named.Method(i))
+			}
 		}
 	}
+	return res
 }
 
-func loadPackageInfo(pkgName string) (*packages.Package, error) {
-	loadMode := packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo
-	cfg := &packages.Config{Mode: loadMode}
+func (a analyzer) loadPackageInfo(pkgName, dir string) (*packages.Package, error) {
+	// Without NeedImports, newer versions of the packages library
+	// fail to provide types of the imported packages.
+	loadMode := packages.NeedName | packages.NeedImports | packages.NeedTypes |
+		packages.NeedTypesInfo | packages.NeedSyntax
+	cfg := a.config.packagesConfig(loadMode, dir)
 	pkgs, err := packages.Load(cfg, string(pkgName))
 	if err != nil {
 		return nil, fmt.Errorf("load package: %v", err)
@@ -97,20 +226,20 @@ func loadPackageInfo(pkgName string) (*packages.Package, error) {
 	return pkgs[0], nil
 }
 
-func exportFacts(facts Result, info *types.Info, files []*ast.File) {
+func exportFacts(facts Result, e extractor, files []*ast.File) {
 	for _, file := range files {
 		for _, decl := range file.Decls {
-			exportFact(facts, info, decl)
+			exportFact(facts, e, decl)
 		}
 	}
 }
 
-func exportFact(facts Result, info *types.Info, decl ast.Decl) {
+func exportFact(facts Result, e extractor, decl ast.Decl) {
 	fdecl, ok := decl.(*ast.FuncDecl)
 	if !ok || fdecl.Body == nil { // not a func declaration or func without a body
 		return
 	}
-	obj, ok := info.Defs[fdecl.Name].(*types.Func)
+	obj, ok := e.info.Defs[fdecl.Name].(*types.Func)
 	if !ok {
 		return
 	}
@@ -119,13 +248,158 @@ func exportFact(facts Result, info *types.Info, decl ast.Decl) {
 		return
 	}
 
-	fact := functionFromAST(fdecl, info)
+	fact, _ := e.functionOf(obj)
 	if fact == nil {
 		return
 	}
 	facts[obj] = fact
 }
 
+// findDecls finds declarations of all functions with a body.
+func findDecls(info *types.Info, files []*ast.File) map[*types.Func]*ast.FuncDecl {
+	res := make(map[*types.Func]*ast.FuncDecl)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fdecl, ok := decl.(*ast.FuncDecl)
+			if !ok || fdecl.Body == nil {
+				continue
+			}
+			obj, ok := info.Defs[fdecl.Name].(*types.Func)
+			if ok {
+				res[obj] = fdecl
+			}
+		}
+	}
+	return res
+}
+
+// findSentinels finds values of all package-level variables.
+//
+// It is used to resolve messages of sentinel errors, like `ErrNotFound = errors.New("not found")`.
+func findSentinels(info *types.Info, files []*ast.File) map[*types.Var]ast.Expr {
+	res := make(map[*types.Var]ast.Expr)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			nGen, ok := decl.(*ast.GenDecl)
+			if !ok || nGen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range nGen.Specs {
+				nSpec := spec.(*ast.ValueSpec)
+				if len(nSpec.Names) != len(nSpec.Values) {
+					continue
+				}
+				for i, nIdent := range nSpec.Names {
+					obj, ok := info.Defs[nIdent].(*types.Var)
+					if ok {
+						res[obj] = nSpec.Values[i]
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// findConstMaps finds package-level maps with constant keys that are never modified.
+//
+// The map is considered read-only if it is only indexed (but not assigned by index),
+// iterated over, or passed into len. Any other usage might modify the map.
+// Exported maps might be modified by other packages, so they are skipped.
+func findConstMaps(
+	info *types.Info,
+	files []*ast.File,
+	values map[*types.Var]ast.Expr,
+) map[*types.Var][]constant.Value {
+	res := make(map[*types.Var][]constant.Value)
+	for obj, nValue := range values {
+		if obj.Exported() {
+			continue
+		}
+		nLit, ok := nValue.(*ast.CompositeLit)
+		if !ok {
+			continue
+		}
+		_, isMap := obj.Type().Underlying().(*types.Map)
+		if !isMap {
+			continue
+		}
+		keys := make([]constant.Value, 0, len(nLit.Elts))
+		for _, nElt := range nLit.Elts {
+			nKV, ok := nElt.(*ast.KeyValueExpr)
+			if !ok {
+				break
+			}
+			keyType := info.Types[nKV.Key]
+			if keyType.Value == nil {
+				break
+			}
+			keys = append(keys, keyType.Value)
+		}
+		if len(keys) == len(nLit.Elts) {
+			res[obj] = keys
+		}
+	}
+
+	// exclude maps that might be modified
+	for _, file := range files {
+		stack := make([]ast.Node, 0)
+		ast.Inspect(file, func(node ast.Node) bool {
+			if node == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+			nIdent, ok := node.(*ast.Ident)
+			if ok {
+				obj, isVar := info.Uses[nIdent].(*types.Var)
+				if isVar && !isMapRead(nIdent, stack, info) {
+					delete(res, obj)
+				}
+			}
+			stack = append(stack, node)
+			return true
+		})
+	}
+	return res
+}
+
+// isMapRead checks if the map variable is used in a way that doesn't modify the map.
+func isMapRead(nIdent *ast.Ident, stack []ast.Node, info *types.Info) bool {
+	if len(stack) < 2 {
+		return false
+	}
+	switch parent := stack[len(stack)-1].(type) {
+	case *ast.IndexExpr:
+		if parent.X != nIdent {
+			return true // the map is used as an index, not modified
+		}
+		switch grand := stack[len(stack)-2].(type) {
+		case *ast.AssignStmt:
+			for _, nExpr := range grand.Lhs {
+				if nExpr == parent {
+					return false
+				}
+			}
+			return true
+		case *ast.IncDecStmt:
+			return false
+		case *ast.UnaryExpr:
+			return grand.Op != token.AND
+		}
+		return true
+	case *ast.RangeStmt:
+		return parent.X == nIdent
+	case *ast.CallExpr:
+		nFun, ok := parent.Fun.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		builtin, ok := info.Uses[nFun].(*types.Builtin)
+		return ok && builtin.Name() == "len"
+	}
+	return false
+}
+
 func getImportPath(nImport *ast.ImportSpec) string {
 	if nImport.Path == nil {
 		return ""
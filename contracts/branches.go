@@ -0,0 +1,248 @@
+package contracts
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// contractsFromAST returns all contracts defined by the statement.
+//
+// Besides simple if statements, it supports if/else if chains, switch
+// statements, and calls of guard helpers and wrapped functions.
+// Each terminating branch becomes its own contract. A branch is
+// reached only if conditions of all previous branches are false,
+// so their negation is a part of the branch contract.
+//
+// The second result value is false if the statement might do something
+// besides checking contracts. Then no contracts after it can be trusted.
+func (e extractor) contractsFromAST(node ast.Node) ([]Contract, bool) {
+	switch v := node.(type) {
+	case *ast.IfStmt:
+		if v.Else == nil {
+			if v.Init != nil {
+				found, isGuard := e.contractsFromErrCheck(v.Init, v)
+				if len(found) != 0 {
+					return found, isGuard
+				}
+			}
+			contract, err := e.contractFromAST(v)
+			if err != nil {
+				return nil, false
+			}
+			return []Contract{*contract}, true
+		}
+		return e.contractsFromChain(v)
+	case *ast.SwitchStmt:
+		return e.contractsFromSwitch(v)
+	case *ast.ExprStmt, *ast.AssignStmt, *ast.ReturnStmt:
+		return e.contractsFromCallStmt(v.(ast.Stmt))
+	}
+	return nil, false
+}
+
+// contractsFromChain extracts contracts from if/else if/else chain.
+func (e extractor) contractsFromChain(nIf *ast.IfStmt) ([]Contract, bool) {
+	b := newBranches(e)
+	for {
+		if nIf.Init != nil && !b.bind(nIf.Init) {
+			return b.contracts, false
+		}
+		cond, names, err := b.e.expr2string(nIf.Cond)
+		if err != nil {
+			return b.contracts, false
+		}
+		b.track(nIf.Cond)
+		b.add(nIf.Pos(), cond, "!("+cond+")", names, nIf.Body.List)
+
+		switch nElse := nIf.Else.(type) {
+		case *ast.IfStmt:
+			nIf = nElse
+		case *ast.BlockStmt:
+			b.addDefault(nElse.Pos(), nElse.List)
+			return b.contracts, b.transparent
+		default:
+			return b.contracts, b.transparent
+		}
+	}
+}
+
+// contractsFromSwitch extracts contracts from a switch statement.
+//
+// Both switches with a tag (`switch x {}`) and without (`switch {}`) are supported.
+func (e extractor) contractsFromSwitch(nSwitch *ast.SwitchStmt) ([]Contract, bool) {
+	b := newBranches(e)
+	if nSwitch.Init != nil && !b.bind(nSwitch.Init) {
+		return nil, false
+	}
+	tag := ""
+	tagNames := make([]string, 0)
+	if nSwitch.Tag != nil {
+		var err error
+		tag, tagNames, err = b.e.expr2string(nSwitch.Tag)
+		if err != nil {
+			return nil, false
+		}
+		tag = parenthesize(nSwitch.Tag, tag)
+		b.track(nSwitch.Tag)
+	}
+
+	// values of cases that don't fail, shown in the message for the default case
+	accepted := make([]string, 0)
+	var nDefault *ast.CaseClause
+	for _, stmt := range nSwitch.Body.List {
+		nCase := stmt.(*ast.CaseClause)
+		if nCase.List == nil { // "default" is checked after all other cases
+			nDefault = nCase
+			continue
+		}
+		parts := make([]string, 0, len(nCase.List))
+		negated := make([]string, 0, len(nCase.List))
+		names := make([]string, 0)
+		for _, nExpr := range nCase.List {
+			part, partNames, err := b.e.expr2string(nExpr)
+			if err != nil {
+				return b.contracts, false
+			}
+			b.track(nExpr)
+			if tag != "" {
+				value := parenthesize(nExpr, part)
+				part = tag + " == " + value
+				negated = append(negated, tag+" != "+value)
+				partNames = append(partNames, tagNames...)
+			}
+			parts = append(parts, part)
+			names = append(names, partNames...)
+		}
+		cond := strings.Join(parts, " || ")
+		neg := "!(" + cond + ")"
+		if tag != "" {
+			neg = strings.Join(negated, " && ")
+		}
+		count := len(b.contracts)
+		b.add(nCase.Pos(), cond, neg, names, nCase.Body)
+		if len(b.contracts) == count {
+			for _, nExpr := range nCase.List {
+				accepted = append(accepted, b.e.source(nExpr))
+			}
+		}
+	}
+
+	if nDefault != nil {
+		count := len(b.contracts)
+		b.addDefault(nDefault.Pos(), nDefault.Body)
+		if tag != "" && len(b.contracts) > count {
+			b.contracts[count].addAccepted(accepted)
+		}
+	}
+	return b.contracts, b.transparent
+}
+
+// addAccepted adds the list of accepted values into the contract message.
+func (c *Contract) addAccepted(values []string) {
+	if len(values) == 0 {
+		return
+	}
+	suffix := fmt.Sprintf(" (accepted values: %s)", strings.Join(values, ", "))
+	c.Message += suffix
+	if c.Format != "" {
+		c.Format += strings.ReplaceAll(suffix, "%", "%%")
+	}
+}
+
+// parenthesize wraps the rendered expression in parenthesis if it's a binary expression.
+//
+// It is needed to keep the operator precedence when the expression is a part of a bigger one.
+func parenthesize(expr ast.Expr, rendered string) string {
+	_, isBinary := unparen(expr).(*ast.BinaryExpr)
+	if isBinary {
+		return "(" + rendered + ")"
+	}
+	return rendered
+}
+
+// branches accumulates contracts for branches checked one after another.
+type branches struct {
+	e         extractor
+	negated   []string                  // negated conditions of all previous branches
+	names     []string                  // unbound variables used by previous conditions
+	funcs     []string                  // pure functions called by all conditions
+	guarded   map[types.Object]struct{} // variables used by all conditions
+	contracts []Contract
+
+	// transparent is true if every branch either terminates or does nothing.
+	transparent bool
+}
+
+func newBranches(e extractor) *branches {
+	return &branches{
+		e:           e,
+		negated:     make([]string, 0),
+		names:       make([]string, 0),
+		funcs:       make([]string, 0),
+		guarded:     make(map[types.Object]struct{}),
+		contracts:   make([]Contract, 0),
+		transparent: true,
+	}
+}
+
+// bind adds variables defined in the init statement to the known substitutions.
+func (b *branches) bind(nInit ast.Stmt) bool {
+	subst, err := b.e.bindInit(nInit)
+	if err != nil {
+		return false
+	}
+	merged := make(map[types.Object]binding)
+	for obj, bound := range b.e.subst {
+		merged[obj] = bound
+	}
+	for obj, bound := range subst {
+		merged[obj] = bound
+	}
+	b.e.subst = merged
+	b.track(nInit)
+	return true
+}
+
+// track remembers variables and pure functions used by the node.
+func (b *branches) track(node ast.Node) {
+	for obj := range b.e.usedVars(node) {
+		b.guarded[obj] = struct{}{}
+	}
+	b.funcs = append(b.funcs, b.e.usedFuncs(node)...)
+}
+
+// add adds a branch that is executed if the condition is true
+// and conditions of all previous branches are false.
+//
+// The negated condition is used for all the following branches.
+func (b *branches) add(pos token.Pos, cond, negated string, names []string, body []ast.Stmt) {
+	full := cond
+	if len(b.negated) != 0 {
+		full = strings.Join(b.negated, " && ") + " && (" + cond + ")"
+	}
+	allNames := append(append([]string{}, b.names...), names...)
+	b.addBody(pos, full, allNames, body)
+	b.negated = append(b.negated, negated)
+	b.names = append(b.names, names...)
+}
+
+// addDefault adds a branch that is executed if conditions of all previous branches are false.
+func (b *branches) addDefault(pos token.Pos, body []ast.Stmt) {
+	b.addBody(pos, strings.Join(b.negated, " && "), b.names, body)
+}
+
+func (b *branches) addBody(pos token.Pos, cond string, names []string, body []ast.Stmt) {
+	if len(body) == 0 {
+		return
+	}
+	funcs := append([]string{}, b.funcs...)
+	contract, err := b.e.newContract(pos, cond, names, funcs, b.guarded, body)
+	if err != nil {
+		b.transparent = false
+		return
+	}
+	b.contracts = append(b.contracts, *contract)
+}
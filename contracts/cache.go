@@ -0,0 +1,244 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion must be changed when the cached data format
+// or the contracts extraction changes, to invalidate the old cache.
+const cacheVersion = "1"
+
+// imports is a process-wide cache of contracts of imported packages
+// shared by all passes of all analyzers.
+var imports = &importCache{entries: make(map[cacheKey]*cacheEntry)}
+
+type cacheKey struct {
+	path   string // import path of the package
+	dir    string // directory of the module or workspace the package is loaded from
+	config string // build configuration, see Config.buildKey
+}
+
+// cacheEntry is contracts of a package, extracted at most once.
+type cacheEntry struct {
+	once  sync.Once
+	funcs map[string]*Function
+	err   error
+}
+
+// importCache is a concurrency-safe cache of contracts of imported packages.
+type importCache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+}
+
+// get returns the cached contracts or calls load to get them.
+//
+// If multiple passes request the same package concurrently,
+// the package is loaded only once and all of them wait for it.
+func (c *importCache) get(key cacheKey, load func() (map[string]*Function, error)) (map[string]*Function, error) {
+	c.mu.Lock()
+	entry, found := c.entries[key]
+	if !found {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+	entry.once.Do(func() {
+		entry.funcs, entry.err = load()
+	})
+	return entry.funcs, entry.err
+}
+
+// loadImport returns contracts of the imported package mapped to full names of functions.
+//
+// The contracts are cached in memory for the whole process and on the disk
+// for the future runs, if the cache directory is configured.
+func (a analyzer) loadImport(importPath, dir string) (map[string]*Function, error) {
+	key := cacheKey{importPath, moduleRoot(dir), a.config.buildKey()}
+	return imports.get(key, func() (map[string]*Function, error) {
+		if a.config.CacheDir == "" {
+			return a.extractImport(key)
+		}
+		// The disk cache is best effort. If anything goes wrong,
+		// the contracts are extracted as if there were no cache.
+		hash, err := a.contentHash(key)
+		if err != nil {
+			return a.extractImport(key)
+		}
+		path := filepath.Join(a.config.CacheDir, hash+".gob")
+		funcs, err := readCache(path)
+		if err == nil {
+			return funcs, nil
+		}
+		funcs, err = a.extractImport(key)
+		if err == nil {
+			_ = writeCache(path, funcs)
+		}
+		return funcs, err
+	})
+}
+
+// contentHash returns a hash of the package source code, source code
+// of all its dependencies, and the build configuration.
+//
+// The module directory is not a part of the hash, the same source code
+// has the same contracts no matter where it is loaded from.
+func (a analyzer) contentHash(key cacheKey) (string, error) {
+	loadMode := packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps
+	cfg := a.config.packagesConfig(loadMode, key.dir)
+	pkgs, err := packages.Load(cfg, key.path)
+	if err != nil {
+		return "", fmt.Errorf("load package: %v", err)
+	}
+	if len(pkgs) != 1 {
+		return "", fmt.Errorf("loaded %d packages, expected 1", len(pkgs))
+	}
+	h := hasher{hashes: make(map[*packages.Package]string)}
+	pkgHash, err := h.hash(pkgs[0])
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(cacheVersion + "\n" + key.config + "\n" + pkgHash))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// moduleRoot returns the directory of the workspace (go.work) or the module (go.mod)
+// that the directory belongs to. Packages loaded from any directory
+// of the same module or workspace resolve to the same code.
+//
+// If there is neither, the directory itself is returned.
+func moduleRoot(dir string) string {
+	root := ""
+	for current := dir; ; {
+		if fileExists(filepath.Join(current, "go.work")) {
+			return current
+		}
+		if root == "" && fileExists(filepath.Join(current, "go.mod")) {
+			root = current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	if root == "" {
+		return dir
+	}
+	return root
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// hasher calculates hashes of packages with all their dependencies.
+type hasher struct {
+	hashes map[*packages.Package]string // already hashed packages
+}
+
+func (h hasher) hash(pkg *packages.Package) (string, error) {
+	res, found := h.hashes[pkg]
+	if found {
+		return res, nil
+	}
+	if len(pkg.Errors) != 0 {
+		return "", pkg.Errors[0]
+	}
+	digest := sha256.New()
+	fmt.Fprintf(digest, "package %s\n", pkg.PkgPath)
+	files := append([]string{}, pkg.GoFiles...)
+	sort.Strings(files)
+	for _, path := range files {
+		err := hashFile(digest, path)
+		if err != nil {
+			return "", err
+		}
+	}
+	paths := make([]string, 0, len(pkg.Imports))
+	for path := range pkg.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		depHash, err := h.hash(pkg.Imports[path])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(digest, "import %s %s\n", path, depHash)
+	}
+	res = hex.EncodeToString(digest.Sum(nil))
+	h.hashes[pkg] = res
+	return res, nil
+}
+
+// hashFile writes the file name and content into the digest.
+func hashFile(digest io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file: %v", err)
+	}
+	defer file.Close()
+	fmt.Fprintf(digest, "file %s\n", filepath.Base(path))
+	_, err = io.Copy(digest, file)
+	if err != nil {
+		return fmt.Errorf("read file: %v", err)
+	}
+	return nil
+}
+
+// readCache reads contracts cached on the disk.
+func readCache(path string) (map[string]*Function, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	funcs := make(map[string]*Function)
+	err = gob.NewDecoder(file).Decode(&funcs)
+	if err != nil {
+		return nil, fmt.Errorf("decode cache: %v", err)
+	}
+	return funcs, nil
+}
+
+// writeCache saves contracts on the disk.
+//
+// The file is written into a temporary file first, so that concurrent runs
+// never see a partially written cache.
+func writeCache(path string, funcs map[string]*Function) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("create cache dir: %v", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return fmt.Errorf("create cache file: %v", err)
+	}
+	err = gob.NewEncoder(file).Encode(funcs)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("encode cache: %v", err)
+	}
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("save cache file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package contracts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestImportCacheLoadsOnce(t *testing.T) {
+	t.Parallel()
+	cache := &importCache{entries: make(map[cacheKey]*cacheEntry)}
+	key := cacheKey{path: "example.com/p"}
+	expected := map[string]*Function{"example.com/p.F": {Args: []string{"x"}}}
+	var calls int32
+	load := func() (map[string]*Function, error) {
+		atomic.AddInt32(&calls, 1)
+		return expected, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			funcs, err := cache.get(key, load)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(funcs, expected) {
+				t.Errorf("unexpected contracts: %v", funcs)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("expected the package to be loaded once, loaded %d times", calls)
+	}
+
+	// the same package with a different configuration is loaded again
+	_, _ = cache.get(cacheKey{path: "example.com/p", config: "tags=dev"}, load)
+	if calls != 2 {
+		t.Fatalf("expected the package to be loaded twice, loaded %d times", calls)
+	}
+}
+
+func TestImportCacheKeepsErrors(t *testing.T) {
+	t.Parallel()
+	cache := &importCache{entries: make(map[cacheKey]*cacheEntry)}
+	key := cacheKey{path: "example.com/broken"}
+	calls := 0
+	load := func() (map[string]*Function, error) {
+		calls++
+		return nil, errors.New("cannot load")
+	}
+	for i := 0; i < 2; i++ {
+		_, err := cache.get(key, load)
+		if err == nil || err.Error() != "cannot load" {
+			t.Fatalf("expected the load error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected the package to be loaded once, loaded %d times", calls)
+	}
+}
+
+func TestModuleRoot(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	module := filepath.Join(root, "work", "module")
+	pkg := filepath.Join(module, "internal", "pkg")
+	mustMkdir(t, pkg)
+	outside := filepath.Join(root, "outside")
+	mustMkdir(t, outside)
+
+	mustWrite(t, filepath.Join(module, "go.mod"), "module example.com/m\n")
+	if got := moduleRoot(pkg); got != module {
+		t.Fatalf("expected the module root %s, got %s", module, got)
+	}
+	if got := moduleRoot(outside); got != outside {
+		t.Fatalf("expected the directory itself %s, got %s", outside, got)
+	}
+
+	// workspace takes precedence over the module
+	work := filepath.Join(root, "work")
+	mustWrite(t, filepath.Join(work, "go.work"), "go 1.20\n")
+	if got := moduleRoot(pkg); got != work {
+		t.Fatalf("expected the workspace root %s, got %s", work, got)
+	}
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	funcs := map[string]*Function{
+		"example.com/p.Sqrt": {
+			Args:  []string{"x"},
+			Types: []string{"float64"},
+			Contracts: []Contract{{
+				Condition: "x < 0",
+				Names:     []string{"x"},
+				Message:   "negative number",
+				Panics:    true,
+				Chain:     []Call{{Name: "check"}},
+			}},
+		},
+	}
+	path := filepath.Join(dir, "nested", "p.gob")
+	err := writeCache(path, funcs)
+	if err != nil {
+		t.Fatalf("write cache: %v", err)
+	}
+	actual, err := readCache(path)
+	if err != nil {
+		t.Fatalf("read cache: %v", err)
+	}
+	if !reflect.DeepEqual(actual, funcs) {
+		t.Fatalf("cached contracts differ: %v", actual)
+	}
+
+	// temporary files are cleaned up
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("read cache dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the cache file, got %d files", len(entries))
+	}
+
+	broken := filepath.Join(dir, "broken.gob")
+	mustWrite(t, broken, "not a gob")
+	_, err = readCache(broken)
+	if err == nil {
+		t.Fatal("expected an error for a broken cache file")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	mustWrite(t, filepath.Join(dir, "go.mod"), "module example.com/m\n\ngo 1.20\n")
+	mustWrite(t, filepath.Join(dir, "dep", "dep.go"), "package dep\n\nfunc F(x int) {}\n")
+	mustWrite(t, filepath.Join(dir, "p", "p.go"), "package p\n\nimport _ \"example.com/m/dep\"\n")
+
+	config := NewConfig()
+	config.CacheDir = t.TempDir()
+	a := analyzer{&config}
+	key := cacheKey{"example.com/m/p", dir, config.buildKey()}
+	hash := func(key cacheKey) string {
+		t.Helper()
+		res, err := a.contentHash(key)
+		if err != nil {
+			t.Fatalf("content hash: %v", err)
+		}
+		return res
+	}
+	original := hash(key)
+	if hash(key) != original {
+		t.Fatal("hash of the same code differs")
+	}
+
+	// changes in dependencies invalidate the hash
+	mustWrite(t, filepath.Join(dir, "dep", "dep.go"), "package dep\n\nfunc F(x int) { panic(x) }\n")
+	changed := hash(key)
+	if changed == original {
+		t.Fatal("hash didn't change after changing a dependency")
+	}
+
+	// the build configuration is a part of the hash
+	other := NewConfig()
+	other.BuildEnv = []string{"CGO_ENABLED=0"}
+	key.config = other.buildKey()
+	if hash(key) == changed {
+		t.Fatal("hash didn't change after changing the build configuration")
+	}
+}
+
+func mustMkdir(t *testing.T, dir string) {
+	t.Helper()
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+}
+
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	mustMkdir(t, filepath.Dir(path))
+	err := os.WriteFile(path, []byte(content), 0o644)
+	if err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+}
@@ -1,17 +1,87 @@
 package contracts
 
-import "flag"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
 
 type Config struct {
 	FollowImports   bool
 	ReportContracts bool
+	PureFuncs       []string // full names of stdlib functions that contracts may call
+	Terminators     []string // full names of functions that act like panic
+	CacheDir        string   // directory for contracts of imported packages, empty to not cache
+	Packages        Filter   // packages to extract contracts from
+	BuildTags       []string // extra build tags for loading imported packages
+	BuildEnv        []string // extra environment variables for loading imported packages, like GOOS=linux
 }
 
 func NewConfig() Config {
 	return Config{
 		FollowImports:   true,
 		ReportContracts: false,
+		PureFuncs:       append([]string{}, PureFuncs...),
+		Terminators:     append([]string{}, Terminators...),
+		CacheDir:        defaultCacheDir(),
+		Packages:        Filter{},
+	}
+}
+
+// defaultCacheDir returns the directory in the user cache, if there is one.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(dir, "arguard")
+}
+
+// buildVars are environment variables that affect which files
+// are included into the build and how constants are evaluated.
+var buildVars = []string{
+	"GOOS", "GOARCH", "GOFLAGS", "GOWORK", "CGO_ENABLED", "GOEXPERIMENT",
+	"GO386", "GOAMD64", "GOARM", "GOARM64", "GOMIPS", "GOMIPS64",
+	"GOPPC64", "GORISCV64", "GOWASM",
+}
+
+// buildKey returns a string identifying everything that affects contracts
+// extracted from a package besides the package itself.
+func (c *Config) buildKey() string {
+	parts := make([]string, 0, len(buildVars)+3+len(c.BuildEnv))
+	for _, name := range buildVars {
+		parts = append(parts, name+"="+os.Getenv(name))
+	}
+	parts = append(parts,
+		"tags="+strings.Join(c.BuildTags, ","),
+		"pure="+strings.Join(c.PureFuncs, ","),
+		"terminators="+strings.Join(c.Terminators, ","),
+	)
+	parts = append(parts, c.BuildEnv...)
+	return strings.Join(parts, "\n")
+}
+
+// packagesConfig returns the configuration for loading imported packages.
+//
+// The environment of the current process, which includes GOFLAGS,
+// GOOS, and the like, is passed to the go command, with the extra
+// tags and variables from the config. The directory should be
+// the directory of the analyzed package, so that imports
+// are resolved to the same module versions.
+func (c *Config) packagesConfig(mode packages.LoadMode, dir string) *packages.Config {
+	cfg := &packages.Config{
+		Mode: mode,
+		Dir:  dir,
+		Env:  append(os.Environ(), c.BuildEnv...),
+	}
+	if len(c.BuildTags) != 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(c.BuildTags, ",")}
+	}
+	return cfg
 }
 
 func (c *Config) flagSet() *flag.FlagSet {
@@ -24,5 +94,58 @@ func (c *Config) flagSet() *flag.FlagSet {
 		&c.ReportContracts, "report-contracts", c.ReportContracts,
 		"report all detected contracts, useful for debugging and testing",
 	)
+	fs.Func(
+		"pure-funcs",
+		"comma-separated list of extra stdlib functions that contracts may call, like strings.EqualFold",
+		appendList(&c.PureFuncs),
+	)
+	fs.Func(
+		"terminators",
+		"comma-separated list of extra functions that never return, like example.com/must.Fail",
+		appendList(&c.Terminators),
+	)
+	fs.StringVar(
+		&c.CacheDir, "cache-dir", c.CacheDir,
+		"directory to cache contracts of imported packages in, empty to not cache",
+	)
+	fs.Func(
+		"tags",
+		"comma-separated list of extra build tags for loading imported packages",
+		appendList(&c.BuildTags),
+	)
+	fs.Func(
+		"env",
+		"extra environment variable for loading imported packages, like GOOS=linux, can be repeated",
+		func(value string) error {
+			if !strings.Contains(value, "=") {
+				return fmt.Errorf("expected KEY=VALUE, got %q", value)
+			}
+			c.BuildEnv = append(c.BuildEnv, value)
+			return nil
+		},
+	)
+	c.Packages.AddFlags(fs)
 	return fs
 }
+
+// appendList returns a flag parser that appends comma-separated values to the target.
+func appendList(target *[]string) func(string) error {
+	return func(value string) error {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				*target = append(*target, name)
+			}
+		}
+		return nil
+	}
+}
+
+// toSet converts the list of names into a set.
+func toSet(names []string) map[string]struct{} {
+	res := make(map[string]struct{})
+	for _, name := range names {
+		res[name] = struct{}{}
+	}
+	return res
+}
@@ -0,0 +1,78 @@
+package contracts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildTags(t *testing.T) {
+	t.Parallel()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	extract := func(tags ...string) map[string]*Function {
+		t.Helper()
+		config := NewConfig()
+		config.CacheDir = ""
+		config.BuildTags = tags
+		// testdata is a GOPATH, not a module
+		config.BuildEnv = []string{"GOPATH=" + testdata, "GO111MODULE=off", "GOFLAGS="}
+		a := analyzer{&config}
+		funcs, err := a.extractImport(cacheKey{"tags", filepath.Join(testdata, "src", "tags"), config.buildKey()})
+		if err != nil {
+			t.Fatalf("extract contracts: %v", err)
+		}
+		return funcs
+	}
+
+	funcs := extract()
+	if _, found := funcs["tags.Open"]; !found {
+		t.Fatal("contracts of tags.Open are not extracted")
+	}
+	if _, found := funcs["tags.Debug"]; found {
+		t.Fatal("contracts of tags.Debug are extracted without the dev tag")
+	}
+
+	funcs = extract("dev")
+	fn, found := funcs["tags.Debug"]
+	if !found {
+		t.Fatal("contracts of tags.Debug are not extracted with the dev tag")
+	}
+	if len(fn.Contracts) != 1 || fn.Contracts[0].Message != "negative level" {
+		t.Fatalf("unexpected contracts of tags.Debug: %v", fn.Contracts)
+	}
+}
+
+func TestBuildKey(t *testing.T) {
+	t.Parallel()
+	config := NewConfig()
+	base := config.buildKey()
+	if config.buildKey() != base {
+		t.Fatal("build key of the same configuration differs")
+	}
+
+	config.BuildTags = []string{"dev"}
+	withTags := config.buildKey()
+	if withTags == base {
+		t.Fatal("build key didn't change after adding build tags")
+	}
+
+	config.BuildEnv = []string{"GOOS=plan9"}
+	if config.buildKey() == withTags {
+		t.Fatal("build key didn't change after adding environment variables")
+	}
+}
+
+func TestBuildKeyEnv(t *testing.T) {
+	config := NewConfig()
+	for _, name := range []string{"GOEXPERIMENT", "GOAMD64", "GOARM", "GO386"} {
+		before := config.buildKey()
+		t.Setenv(name, "test")
+		if config.buildKey() == before {
+			t.Fatalf("build key didn't change after setting %s", name)
+		}
+	}
+}
@@ -4,19 +4,34 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/token"
 	"go/types"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/traefik/yaegi/interp"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
 type Contract struct {
 	Pos       token.Pos // contract position, used for positioning debug messages
 	Condition string    // valid Go-syntax expression which if true, the contract is violated
 	Names     []string  // unbound variables used by the condition
+	Funcs     []string  // full names of pure functions called by the condition
 	Message   string    // error message to show on contract failure
+	Panics    bool      // the function panics on failure instead of returning an error
+
+	// If the message is formatted, the format string and its arguments.
+	// Used to render the message with the actual argument values on violation.
+	Format     string
+	FormatArgs []MessageArg
+
+	// If the contract is inherited from a called function, the calls through which
+	// it is inherited, starting from the one in the function the contract belongs to.
+	Chain []Call
 }
 
 // contractFromAST returns a contract if the given AST node looks like one.
@@ -24,26 +39,142 @@ type Contract struct {
 // The returned error explains why the node cannot be converted into a contract.
 // It might be not an if statement, not use input args, be unsafe to statically execute
 // and lots of other reasons. Most of the real code isn't a contract.
-func contractFromAST(node ast.Node, info *types.Info) (*Contract, error) {
+func (e extractor) contractFromAST(node ast.Node) (*Contract, error) {
 	nIf, ok := node.(*ast.IfStmt)
 	if !ok {
 		return nil, errors.New("not an if statement")
 	}
-	cond, names, err := expr2string(nIf.Cond, info)
+	guarded := e.usedVars(nIf.Cond)
+	funcs := e.usedFuncs(nIf.Cond)
+	if nIf.Init != nil {
+		subst, err := e.bindInit(nIf.Init)
+		if err != nil {
+			return nil, fmt.Errorf("extract init: %v", err)
+		}
+		e.subst = subst
+		for obj := range e.usedVars(nIf.Init) {
+			guarded[obj] = struct{}{}
+		}
+		funcs = append(funcs, e.usedFuncs(nIf.Init)...)
+	}
+	cond, names, err := e.expr2string(nIf.Cond)
 	if err != nil {
 		return nil, fmt.Errorf("extract condition: %v", err)
 	}
+	return e.newContract(node.Pos(), cond, names, funcs, guarded, nIf.Body.List)
+}
+
+// newContract creates a contract if the body looks like a guard body.
+//
+// The guarded variables are the ones used by the condition.
+// The body must not modify them before failing.
+func (e extractor) newContract(
+	pos token.Pos,
+	cond string,
+	names []string,
+	funcs []string,
+	guarded map[types.Object]struct{},
+	body []ast.Stmt,
+) (*Contract, error) {
 	if len(names) == 0 {
 		return nil, errors.New("condition is static (uses no variables)")
 	}
-	msg, isError := extractMessage(nIf.Body, info)
+	msg, isError := e.extractMessage(body, guarded)
 	if !isError {
 		return nil, errors.New("body doesn't look like a contract")
 	}
-	if msg == "" {
-		msg = "should be false: " + cond
+	if msg.text == "" {
+		msg.text = "should be false: " + cond
 	}
-	return &Contract{node.Pos(), cond, names, msg}, nil
+	return &Contract{
+		Pos:        pos,
+		Condition:  cond,
+		Names:      names,
+		Funcs:      funcs,
+		Message:    msg.text,
+		Panics:     msg.panics,
+		Format:     msg.format,
+		FormatArgs: msg.args,
+	}, nil
+}
+
+// binding is a variable defined in the init statement of a guard.
+type binding struct {
+	expr  string   // valid Go-syntax expression for the variable value
+	names []string // unbound variables used by the expression
+}
+
+// bindInit converts the init statement of an if statement into bindings.
+//
+// Only short variable declarations without side effects are supported,
+// so that variables can be substituted with their values in the condition.
+func (e extractor) bindInit(nInit ast.Stmt) (map[types.Object]binding, error) {
+	nAssign, ok := nInit.(*ast.AssignStmt)
+	if !ok || nAssign.Tok != token.DEFINE {
+		return nil, errors.New("init is not a short variable declaration")
+	}
+	if len(nAssign.Lhs) == 2 && len(nAssign.Rhs) == 1 {
+		return e.bindCommaOk(nAssign)
+	}
+	if len(nAssign.Lhs) != len(nAssign.Rhs) {
+		return nil, errors.New("unsupported assignment")
+	}
+	res := make(map[types.Object]binding)
+	for i, nExpr := range nAssign.Lhs {
+		obj := e.info.Defs[nExpr.(*ast.Ident)]
+		if obj == nil || obj.Name() == "_" {
+			continue
+		}
+		nValue := nAssign.Rhs[i]
+		expr, names, err := e.expr2string(nValue)
+		if err != nil {
+			return nil, err
+		}
+		// keep the operator precedence when the variable is substituted
+		res[obj] = binding{parenthesize(nValue, expr), names}
+	}
+	return res, nil
+}
+
+// bindCommaOk converts `_, ok := m[key]` into a binding for `ok`.
+//
+// The map must be a read-only package-level map with constant keys,
+// so that `ok` can be replaced by comparing the key with all map keys.
+func (e extractor) bindCommaOk(nAssign *ast.AssignStmt) (map[types.Object]binding, error) {
+	nIndex, ok := unparen(nAssign.Rhs[0]).(*ast.IndexExpr)
+	if !ok {
+		return nil, errors.New("unsupported assignment")
+	}
+	mapVar, ok := e.objectOf(unparen(nIndex.X)).(*types.Var)
+	if !ok {
+		return nil, errors.New("indexed value is not a variable")
+	}
+	keys, found := e.constMaps[mapVar]
+	if !found {
+		return nil, errors.New("indexed value is not a read-only map")
+	}
+	key, names, err := e.expr2string(nIndex.Index)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[types.Object]binding)
+	if nAssign.Lhs[0].(*ast.Ident).Name != "_" {
+		return nil, errors.New("map value is used")
+	}
+	obj := e.info.Defs[nAssign.Lhs[1].(*ast.Ident)]
+	if obj == nil {
+		return res, nil
+	}
+	if len(keys) == 0 {
+		res[obj] = binding{"false", nil}
+		return res, nil
+	}
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s == %s", key, FormatConstant(k)))
+	}
+	res[obj] = binding{"(" + strings.Join(parts, " || ") + ")", names}
+	return res, nil
 }
 
 // allDefined checks if vars define all unbound variables needed to execute the contract.
@@ -70,29 +201,78 @@ func (c Contract) validate(interpreter *interp.Interpreter) (bool, error) {
 	return !condOk, nil
 }
 
+// formatMessage renders the formatted message using the argument values known to the interpreter.
+func (c Contract) formatMessage(interpreter *interp.Interpreter) string {
+	if c.Format == "" {
+		return c.Message
+	}
+	args := make([]any, len(c.FormatArgs))
+	for i, arg := range c.FormatArgs {
+		args[i] = unknownArg(arg.Text)
+		if arg.Expr == "" {
+			continue
+		}
+		res, err := safeEval(interpreter, arg.Expr)
+		if err != nil || !res.IsValid() || !res.CanInterface() {
+			continue
+		}
+		args[i] = res.Interface()
+	}
+	return fmt.Sprintf(c.Format, args...)
+}
+
 // expr2string converts the given AST expression into a valid Go syntax string.
 //
 // Returns an error for unsupported or not safe to execute expressions.
-func expr2string(expr ast.Expr, info *types.Info) (string, []string, error) {
+func (e extractor) expr2string(expr ast.Expr) (string, []string, error) {
+	// constant expressions, including constants from other packages,
+	// are replaced by their value
+	folded := e.foldConstant(expr)
+	if folded != "" {
+		return folded, nil, nil
+	}
 	switch v := expr.(type) {
 	case *ast.BinaryExpr:
-		lExpr, lNames, err := expr2string(v.X, info)
+		lExpr, lNames, err := e.expr2string(v.X)
 		if err != nil {
 			return "", nil, err
 		}
-		rExpr, rNames, err := expr2string(v.Y, info)
+		rExpr, rNames, err := e.expr2string(v.Y)
 		if err != nil {
 			return "", nil, err
 		}
 		cond := fmt.Sprintf("%s %s %s", lExpr, v.Op.String(), rExpr)
 		names := append(lNames, rNames...)
 		return cond, names, nil
+	case *ast.UnaryExpr:
+		return e.unary2string(v)
+	case *ast.ParenExpr:
+		inner, names, err := e.expr2string(v.X)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + inner + ")", names, nil
+	case *ast.CallExpr:
+		if e.isBuiltin(v.Fun, "len", "cap") {
+			return e.builtin2string(v)
+		}
+		callee, isFunc := typeutil.Callee(e.info, v).(*types.Func)
+		if isFunc {
+			return e.call2string(v, callee)
+		}
+		return e.conversion2string(v)
+	case *ast.CompositeLit:
+		return e.composite2string(v)
 	case *ast.BasicLit:
 		return v.Value, nil, nil
 	case *ast.Ident:
-		folded := foldConstant(v, info)
-		if folded != "" {
-			return folded, nil, nil
+		_, isNil := e.info.Uses[v].(*types.Nil)
+		if isNil {
+			return "nil", nil, nil
+		}
+		bound, isBound := e.subst[e.info.Uses[v]]
+		if isBound {
+			return bound.expr, bound.names, nil
 		}
 		return v.Name, []string{v.Name}, nil
 	default:
@@ -100,100 +280,287 @@ func expr2string(expr ast.Expr, info *types.Info) (string, []string, error) {
 	}
 }
 
-func foldConstant(nIdent *ast.Ident, info *types.Info) string {
-	constType, ok := info.Types[nIdent]
-	if !ok {
-		return ""
+// unary2string converts a unary expression into a valid Go syntax string.
+//
+// Only operators without side effects are supported. Taking an address
+// and receiving from a channel are not.
+func (e extractor) unary2string(nUnary *ast.UnaryExpr) (string, []string, error) {
+	switch nUnary.Op {
+	case token.SUB, token.ADD, token.NOT, token.XOR:
+	default:
+		return "", nil, fmt.Errorf("unsupported unary operator: %s", nUnary.Op)
 	}
-	if constType.Value == nil {
-		return ""
+	inner, names, err := e.expr2string(nUnary.X)
+	if err != nil {
+		return "", nil, err
+	}
+	op := nUnary.Op.String()
+	// "- -1" must not become "--1" which is a decrement token
+	if strings.HasPrefix(inner, op) {
+		inner = "(" + inner + ")"
 	}
-	return constType.Value.ExactString()
+	return op + inner, names, nil
 }
 
-// extractMessage extracts error message for the contract.
-//
-// The first result value is the extraccted error message
-// which might be empty if it cannot be extracted.
-//
-// The second result value tells if the given code block
-// is an error of some kind typical for a contract.
-// A contract must either panic or return an error as one of the return values.
-func extractMessage(nBody *ast.BlockStmt, info *types.Info) (string, bool) {
-	if nBody.List == nil {
-		return "", false
+// isBuiltin checks if the expression refers to one of the given built-in functions.
+func (e extractor) isBuiltin(expr ast.Expr, names ...string) bool {
+	nIdent, ok := expr.(*ast.Ident)
+	if !ok {
+		return false
 	}
-	if len(nBody.List) != 1 {
-		return "", false
+	builtin, ok := e.info.Uses[nIdent].(*types.Builtin)
+	if !ok {
+		return false
 	}
-	nStmt := nBody.List[0]
-
-	// check if it's panic
-	nExpr, ok := nStmt.(*ast.ExprStmt)
-	if ok {
-		return extractMessageFromPanic(nExpr)
+	for _, name := range names {
+		if builtin.Name() == name {
+			return true
+		}
 	}
+	return false
+}
 
-	nRet, ok := nStmt.(*ast.ReturnStmt)
-	if ok {
-		return extractMessageFromReturn(nRet, info)
+// builtin2string converts a call of a pure built-in function into a valid Go syntax string.
+func (e extractor) builtin2string(nCall *ast.CallExpr) (string, []string, error) {
+	nIdent := nCall.Fun.(*ast.Ident)
+	if len(nCall.Args) != 1 {
+		return "", nil, fmt.Errorf("%s must have exactly one argument", nIdent.Name)
+	}
+	inner, names, err := e.expr2string(nCall.Args[0])
+	if err != nil {
+		return "", nil, err
 	}
-	return "", false
+	return fmt.Sprintf("%s(%s)", nIdent.Name, inner), names, nil
 }
 
-func extractMessageFromPanic(nExpr *ast.ExprStmt) (string, bool) {
-	// check if the expression is a "panic"
-	if nExpr.X == nil {
-		return "", false
+// call2string converts a call of a pure function into a valid Go syntax string.
+//
+// The function is always referred to by its package name, even if it's imported
+// under an alias, because that's how the interpreter knows it.
+func (e extractor) call2string(nCall *ast.CallExpr, callee *types.Func) (string, []string, error) {
+	if !e.isPure(callee) {
+		return "", nil, fmt.Errorf("function is not known to be pure: %s", callee.FullName())
 	}
-	nCall, ok := nExpr.X.(*ast.CallExpr)
-	if !ok {
-		return "", false
+	if nCall.Ellipsis.IsValid() {
+		return "", nil, errors.New("variadic calls are not supported")
 	}
-	if nCall.Fun == nil {
-		return "", false
+	names := make([]string, 0)
+	args := make([]string, 0, len(nCall.Args))
+	for _, nArg := range nCall.Args {
+		arg, argNames, err := e.expr2string(nArg)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, arg)
+		names = append(names, argNames...)
 	}
-	nIdent, ok := nCall.Fun.(*ast.Ident)
+	res := fmt.Sprintf("%s.%s(%s)", callee.Pkg().Name(), callee.Name(), strings.Join(args, ", "))
+	return res, names, nil
+}
+
+// usedFuncs returns full names of all pure functions called in the expression.
+func (e extractor) usedFuncs(node ast.Node) []string {
+	res := make([]string, 0)
+	ast.Inspect(node, func(node ast.Node) bool {
+		nCall, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		callee, ok := typeutil.Callee(e.info, nCall).(*types.Func)
+		if ok && e.isPure(callee) {
+			res = append(res, callee.FullName())
+		}
+		return true
+	})
+	return res
+}
+
+// composite2string converts a slice, array, or map literal into a valid Go syntax string.
+//
+// Struct literals are not supported because the interpreter doesn't know
+// the struct types defined in the analyzed code.
+func (e extractor) composite2string(nLit *ast.CompositeLit) (string, []string, error) {
+	litType, ok := e.info.Types[nLit]
 	if !ok {
-		return "", false
+		return "", nil, errors.New("unknown type of composite literal")
 	}
-	if nIdent.Name != "panic" {
-		return "", false
+	switch litType.Type.Underlying().(type) {
+	case *types.Slice, *types.Array, *types.Map:
+	default:
+		return "", nil, fmt.Errorf("unsupported composite literal type: %s", litType.Type)
 	}
-	if nCall.Args == nil {
-		return "", false
+	typeName, err := typeExpr(litType.Type)
+	if err != nil {
+		return "", nil, err
 	}
-	if len(nCall.Args) != 1 {
-		return "", false
+	names := make([]string, 0)
+	elts := make([]string, 0, len(nLit.Elts))
+	for _, nElt := range nLit.Elts {
+		nKV, isKV := nElt.(*ast.KeyValueExpr)
+		if !isKV {
+			elt, eltNames, err := e.expr2string(nElt)
+			if err != nil {
+				return "", nil, err
+			}
+			elts = append(elts, elt)
+			names = append(names, eltNames...)
+			continue
+		}
+		key, keyNames, err := e.expr2string(nKV.Key)
+		if err != nil {
+			return "", nil, err
+		}
+		val, valNames, err := e.expr2string(nKV.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		elts = append(elts, key+": "+val)
+		names = append(names, keyNames...)
+		names = append(names, valNames...)
 	}
+	return fmt.Sprintf("%s{%s}", typeName, strings.Join(elts, ", ")), names, nil
+}
 
-	// extract the error message
-	nArg := nCall.Args[0]
-	nLit, ok := nArg.(*ast.BasicLit)
-	if ok {
-		if nLit.Kind == token.STRING {
-			return strings.Trim(nLit.Value, `"`), true
-		}
-		return nLit.Value, true
+// conversion2string converts a type conversion into a valid Go syntax string.
+//
+// Function calls are not supported, only conversions to types that
+// can be represented in the interpreter.
+func (e extractor) conversion2string(nCall *ast.CallExpr) (string, []string, error) {
+	funType, ok := e.info.Types[nCall.Fun]
+	if !ok || !funType.IsType() {
+		return "", nil, errors.New("function calls are not supported")
 	}
-	return "", true
+	if len(nCall.Args) != 1 {
+		return "", nil, errors.New("conversion must have exactly one argument")
+	}
+	typeName, err := typeExpr(funType.Type)
+	if err != nil {
+		return "", nil, err
+	}
+	inner, names, err := e.expr2string(nCall.Args[0])
+	if err != nil {
+		return "", nil, err
+	}
+	// types like "*int" and "func()" must be wrapped in parenthesis to be converted to
+	if !token.IsIdentifier(typeName) {
+		typeName = "(" + typeName + ")"
+	}
+	return fmt.Sprintf("%s(%s)", typeName, inner), names, nil
 }
 
-func extractMessageFromReturn(nRet *ast.ReturnStmt, info *types.Info) (string, bool) {
-	if nRet.Results == nil {
-		return "", false
+// isEllipsis checks if the parameter type is variadic, like `...int`.
+func isEllipsis(expr ast.Expr) bool {
+	_, ok := expr.(*ast.Ellipsis)
+	return ok
+}
+
+// typeExpr converts the type into a Go syntax type expression known to the interpreter.
+//
+// The interpreter doesn't know about types defined in the analyzed code,
+// so named types are replaced by their underlying type. It doesn't change
+// the result of arithmetic and comparison which is all that contracts do.
+// For the same reason, structs, functions, and interfaces are replaced
+// by the simplest type of the same kind, which is enough to compare them with nil.
+func typeExpr(t types.Type) (string, error) {
+	return typeExprDepth(t, 0)
+}
+
+func typeExprDepth(t types.Type, depth int) (string, error) {
+	// protect from recursive types, like `type P *P`
+	if depth > 8 {
+		return "", fmt.Errorf("type is too deeply nested: %s", t)
 	}
-	for _, nExpr := range nRet.Results {
-		exprType, ok := info.Types[nExpr]
-		if !ok {
-			continue
+	// the underlying type of a type parameter is its constraint
+	if _, isTypeParam := t.(*types.TypeParam); isTypeParam {
+		return "", fmt.Errorf("unsupported type parameter: %s", t)
+	}
+	switch v := t.Underlying().(type) {
+	case *types.Basic:
+		if v.Info()&types.IsUntyped != 0 || v.Kind() == types.UnsafePointer {
+			return "", fmt.Errorf("unsupported type: %s", t)
+		}
+		return v.Name(), nil
+	case *types.Pointer:
+		elem, err := typeExprDepth(v.Elem(), depth+1)
+		if err != nil {
+			return "", err
+		}
+		return "*" + elem, nil
+	case *types.Slice:
+		elem, err := typeExprDepth(v.Elem(), depth+1)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case *types.Array:
+		elem, err := typeExprDepth(v.Elem(), depth+1)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("[%d]%s", v.Len(), elem), nil
+	case *types.Map:
+		key, err := typeExprDepth(v.Key(), depth+1)
+		if err != nil {
+			return "", err
+		}
+		elem, err := typeExprDepth(v.Elem(), depth+1)
+		if err != nil {
+			return "", err
 		}
-		if exprType.Type.String() == "error" {
-			// TODO: get the error message from fmt.Errorf and alike
-			return "", true
+		return fmt.Sprintf("map[%s]%s", key, elem), nil
+	case *types.Chan:
+		elem, err := typeExprDepth(v.Elem(), depth+1)
+		if err != nil {
+			return "", err
+		}
+		switch v.Dir() {
+		case types.SendOnly:
+			return "chan<- " + elem, nil
+		case types.RecvOnly:
+			return "<-chan " + elem, nil
+		default:
+			return "chan " + elem, nil
 		}
+	case *types.Struct:
+		return "struct{}", nil
+	case *types.Signature:
+		return "func()", nil
+	case *types.Interface:
+		return "interface{}", nil
+	default:
+		return "", fmt.Errorf("unsupported type: %s", t)
+	}
+}
+
+// foldConstant returns the value of the expression if it is known at compile time.
+func (e extractor) foldConstant(expr ast.Expr) string {
+	constType, ok := e.info.Types[expr]
+	if !ok {
+		return ""
+	}
+	if constType.Value == nil {
+		return ""
+	}
+	return FormatConstant(constType.Value)
+}
+
+// FormatConstant converts the constant value into a valid Go syntax string.
+//
+// It can be used to pass statically known argument values into Function.Validate.
+func FormatConstant(val constant.Value) string {
+	if val.Kind() != constant.Float {
+		return val.ExactString()
+	}
+	// ExactString represents floats as fractions, and "1/2" is integer division.
+	f, _ := constant.Float64Val(val)
+	if math.IsInf(f, 0) {
+		return val.ExactString()
+	}
+	res := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(res, ".e") {
+		res += ".0"
 	}
-	return "", false
+	return res
 }
 
 // safeEval evals the expression using the interpreter and catches panics.
@@ -0,0 +1,292 @@
+package contracts
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// usedVars returns all variables referenced in the node.
+func (e extractor) usedVars(node ast.Node) map[types.Object]struct{} {
+	res := make(map[types.Object]struct{})
+	ast.Inspect(node, func(node ast.Node) bool {
+		nIdent, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj, isVar := e.info.Uses[nIdent].(*types.Var)
+		if isVar {
+			res[obj] = struct{}{}
+		}
+		return true
+	})
+	return res
+}
+
+// mutates checks if the node might change the value of any of the given variables.
+//
+// A variable is considered changed if it is assigned, incremented or decremented,
+// or has its address taken, including inside of closures.
+func (e extractor) mutates(node ast.Node, vars map[types.Object]struct{}) bool {
+	isTarget := func(expr ast.Expr) bool {
+		nIdent, ok := unparen(expr).(*ast.Ident)
+		if !ok {
+			return false
+		}
+		obj := e.info.ObjectOf(nIdent)
+		if obj == nil {
+			return false
+		}
+		_, found := vars[obj]
+		return found
+	}
+	found := false
+	ast.Inspect(node, func(node ast.Node) bool {
+		if found {
+			return false
+		}
+		switch v := node.(type) {
+		case *ast.AssignStmt:
+			for _, nExpr := range v.Lhs {
+				found = found || isTarget(nExpr)
+			}
+		case *ast.IncDecStmt:
+			found = isTarget(v.X)
+		case *ast.RangeStmt:
+			if v.Tok == token.ASSIGN {
+				found = isTarget(v.Key) || (v.Value != nil && isTarget(v.Value))
+			}
+		case *ast.UnaryExpr:
+			found = v.Op == token.AND && isTarget(v.X)
+		case *ast.SelectorExpr:
+			// calling a method with pointer receiver implicitly takes the address
+			found = e.takesAddress(v) && isTarget(v.X)
+		}
+		return !found
+	})
+	return found
+}
+
+// mutatesContents checks if the node might change the data that the variable
+// refers to without assigning the variable, like elements of a map or a slice.
+//
+// Besides writing elements, the data might be changed by passing the variable
+// into delete, clear, copy, append, or any function not known to be pure,
+// by sending into or receiving from it (if it's a channel), or through
+// another variable it is assigned to. Variables of basic types are immutable.
+func (e extractor) mutatesContents(node ast.Node, obj types.Object) bool {
+	if _, isBasic := obj.Type().Underlying().(*types.Basic); isBasic {
+		return false
+	}
+	// isTarget checks if the expression refers to the data of the variable
+	isTarget := func(expr ast.Expr) bool {
+		for {
+			switch v := unparen(expr).(type) {
+			case *ast.Ident:
+				return e.info.ObjectOf(v) == obj
+			case *ast.IndexExpr:
+				expr = v.X
+			case *ast.SliceExpr:
+				expr = v.X
+			case *ast.StarExpr:
+				expr = v.X
+			case *ast.SelectorExpr:
+				expr = v.X
+			default:
+				return false
+			}
+		}
+	}
+	// isElem checks if the expression is an element or a field of the variable
+	isElem := func(expr ast.Expr) bool {
+		_, isIdent := unparen(expr).(*ast.Ident)
+		return !isIdent && isTarget(expr)
+	}
+	found := false
+	ast.Inspect(node, func(node ast.Node) bool {
+		if found {
+			return false
+		}
+		switch v := node.(type) {
+		case *ast.AssignStmt:
+			for _, nExpr := range v.Lhs {
+				found = found || isElem(nExpr)
+			}
+			for _, nExpr := range v.Rhs {
+				switch unparen(nExpr).(type) {
+				case *ast.Ident, *ast.SliceExpr:
+					found = found || isTarget(nExpr)
+				}
+			}
+		case *ast.IncDecStmt:
+			found = isElem(v.X)
+		case *ast.SendStmt:
+			found = isTarget(v.Chan)
+		case *ast.UnaryExpr:
+			found = v.Op == token.ARROW && isTarget(v.X)
+		case *ast.CallExpr:
+			found = e.callMutates(v, isTarget)
+		}
+		return !found
+	})
+	return found
+}
+
+// callMutates checks if the call might change the data of any argument
+// (or the method receiver) for which isTarget returns true.
+func (e extractor) callMutates(nCall *ast.CallExpr, isTarget func(ast.Expr) bool) bool {
+	if tv, ok := e.info.Types[nCall.Fun]; ok && tv.IsType() { // type conversion
+		return false
+	}
+	if e.isBuiltin(nCall.Fun, "delete", "clear", "copy", "append") {
+		return len(nCall.Args) != 0 && isTarget(nCall.Args[0])
+	}
+	if nIdent, ok := unparen(nCall.Fun).(*ast.Ident); ok {
+		if _, isBuiltin := e.info.Uses[nIdent].(*types.Builtin); isBuiltin {
+			return false
+		}
+	}
+	callee, ok := typeutil.Callee(e.info, nCall).(*types.Func)
+	if ok && e.isPure(callee) {
+		return false
+	}
+	nSel, ok := unparen(nCall.Fun).(*ast.SelectorExpr)
+	if ok && e.info.Selections[nSel] != nil && isTarget(nSel.X) {
+		return true
+	}
+	for _, nArg := range nCall.Args {
+		if isTarget(nArg) {
+			return true
+		}
+	}
+	return false
+}
+
+// takesAddress checks if the selector is a method with pointer receiver called on a value.
+func (e extractor) takesAddress(nSel *ast.SelectorExpr) bool {
+	sel, ok := e.info.Selections[nSel]
+	if !ok || sel.Kind() != types.MethodVal {
+		return false
+	}
+	sig := sel.Obj().Type().(*types.Signature)
+	_, ptrRecv := sig.Recv().Type().(*types.Pointer)
+	_, ptrValue := sel.Recv().Underlying().(*types.Pointer)
+	return ptrRecv && !ptrValue
+}
+
+// mayPanic checks if the node might call panic or a function that never returns.
+//
+// Closures are checked as well because they might be called immediately.
+func (e extractor) mayPanic(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(node ast.Node) bool {
+		nCall, ok := node.(*ast.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		if e.isBuiltin(nCall.Fun, "panic") {
+			found = true
+			return false
+		}
+		callee, ok := typeutil.Callee(e.info, nCall).(*types.Func)
+		if ok {
+			_, found = e.terminators[callee.FullName()]
+		}
+		return !found
+	})
+	return found
+}
+
+// recovers checks if the node defers a call to recover.
+//
+// If it does, a panic in a contract that goes after it doesn't
+// propagate to the caller, and so the contract isn't enforced.
+func (e extractor) recovers(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(node ast.Node) bool {
+		if found {
+			return false
+		}
+		nDefer, ok := node.(*ast.DeferStmt)
+		if !ok {
+			return true
+		}
+		ast.Inspect(nDefer.Call, func(node ast.Node) bool {
+			nCall, ok := node.(*ast.CallExpr)
+			if ok && e.isBuiltin(nCall.Fun, "recover") {
+				found = true
+			}
+			return !found
+		})
+		return !found
+	})
+	return found
+}
+
+// unparen removes all parenthesis around the expression.
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		nParen, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = nParen.X
+	}
+}
+
+// mayEscape checks if the node might pass the control flow outside of the node
+// other than by falling through or panicking.
+//
+// It includes return statements, goto, and break and continue statements
+// that don't belong to a loop, switch, or select inside of the node.
+// Closures are not checked because returning from them doesn't affect the node.
+func mayEscape(node ast.Node) bool {
+	found := false
+	// all loops and switches enclosing the currently inspected node
+	stack := make([]ast.Node, 0)
+	ast.Inspect(node, func(node ast.Node) bool {
+		if found {
+			return false
+		}
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		switch v := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			found = true
+		case *ast.BranchStmt:
+			found = branchEscapes(v, stack)
+		}
+		stack = append(stack, node)
+		return !found
+	})
+	return found
+}
+
+// branchEscapes checks if the branch statement passes the control flow
+// outside of the inspected node.
+func branchEscapes(nBranch *ast.BranchStmt, stack []ast.Node) bool {
+	switch {
+	case nBranch.Tok == token.FALLTHROUGH:
+		return false
+	case nBranch.Tok == token.GOTO || nBranch.Label != nil:
+		// labels might be outside of the node, be conservative
+		return true
+	}
+	for _, node := range stack {
+		switch node.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return false
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			if nBranch.Tok == token.BREAK {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package contracts
+
+import (
+	"flag"
+	"go/ast"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Filter selects packages by import path patterns.
+//
+// A pattern is either an import path, a path with "..." wildcards
+// like "example.com/...", or "std" for the standard library packages.
+type Filter struct {
+	Include []string // if not empty, only matching packages are selected
+	Exclude []string // matching packages are never selected
+
+	// patterns compiled by the flags, in the same order as the patterns above
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// AddFlags adds flags for include and exclude patterns into the flag set.
+func (f *Filter) AddFlags(fs *flag.FlagSet) {
+	fs.Func(
+		"include",
+		"comma-separated list of package patterns to analyze, like example.com/...",
+		appendPatterns(&f.Include, &f.include),
+	)
+	fs.Func(
+		"exclude",
+		"comma-separated list of package patterns to not analyze, like std for the standard library",
+		appendPatterns(&f.Exclude, &f.exclude),
+	)
+}
+
+// appendPatterns returns a flag parser that appends comma-separated patterns
+// to the target and compiles the new patterns into the compiled list.
+func appendPatterns(target *[]string, compiled *[]*regexp.Regexp) func(string) error {
+	parse := appendList(target)
+	return func(value string) error {
+		err := parse(value)
+		// the patterns were replaced since the last time
+		if len(*compiled) > len(*target) {
+			*compiled = nil
+		}
+		for _, pattern := range (*target)[len(*compiled):] {
+			*compiled = append(*compiled, compilePattern(pattern))
+		}
+		return err
+	}
+}
+
+// Allows checks if the package with the given import path is selected.
+func (f Filter) Allows(path string) bool {
+	if len(f.Include) != 0 && !matchAny(f.Include, f.include, path) {
+		return false
+	}
+	return !matchAny(f.Exclude, f.exclude, path)
+}
+
+// matchAny checks if the import path matches any of the patterns.
+//
+// The patterns are compiled on the fly if the filter wasn't filled by the flags.
+func matchAny(patterns []string, compiled []*regexp.Regexp, path string) bool {
+	for i, pattern := range patterns {
+		var re *regexp.Regexp
+		if len(compiled) == len(patterns) {
+			re = compiled[i]
+		} else {
+			re = compilePattern(pattern)
+		}
+		if matchPattern(pattern, re, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern checks if the import path matches the pattern.
+//
+// The semantic is the same as for the go command: "..." matches any string,
+// and "x/..." also matches "x" itself.
+func matchPattern(pattern string, re *regexp.Regexp, path string) bool {
+	if pattern == "std" {
+		return isStd(path)
+	}
+	if re == nil {
+		return pattern == path
+	}
+	return re.MatchString(path)
+}
+
+// compilePattern converts the pattern with wildcards into a regular expression.
+//
+// Returns nil if the pattern has no wildcards and should be compared as is.
+func compilePattern(pattern string) *regexp.Regexp {
+	if pattern == "std" || !strings.Contains(pattern, "...") {
+		return nil
+	}
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `/\.\.\.`, `(/.*)?`)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	return regexp.MustCompile("^" + re + "$")
+}
+
+// isStd checks if the import path belongs to the standard library.
+//
+// The list of the standard library packages is provided by the go command.
+// If it cannot be loaded, the path is assumed to be in the standard library
+// if its first element has no dot, which is also true for modules like "myapp".
+func isStd(path string) bool {
+	std := stdPackages()
+	if std != nil {
+		_, found := std[path]
+		return found
+	}
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+var (
+	stdOnce  sync.Once
+	stdPaths map[string]struct{}
+)
+
+// stdPackages returns import paths of all standard library packages
+// or nil if the go command fails to list them.
+func stdPackages() map[string]struct{} {
+	stdOnce.Do(func() {
+		pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName}, "std")
+		if err != nil || len(pkgs) == 0 {
+			return
+		}
+		stdPaths = make(map[string]struct{}, len(pkgs))
+		for _, pkg := range pkgs {
+			stdPaths[pkg.PkgPath] = struct{}{}
+		}
+	})
+	return stdPaths
+}
+
+// generatedRx matches the comment marking generated files, see https://go.dev/s/generatedcode.
+var generatedRx = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGenerated checks if the file has the comment marking it as generated code.
+func IsGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			return false
+		}
+		for _, comment := range group.List {
+			if generatedRx.MatchString(comment.Text) {
+				return true
+			}
+		}
+	}
+	return false
+}
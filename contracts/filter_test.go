@@ -0,0 +1,64 @@
+package contracts_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/orsinium-labs/arguard/contracts"
+)
+
+func TestFilterAllows(t *testing.T) {
+	t.Parallel()
+	filter := contracts.Filter{
+		Include: []string{"std", "myapp/...", "example.com/..."},
+		Exclude: []string{"net/...", "example.com/legacy/..."},
+	}
+	cases := map[string]bool{
+		"fmt":                    true,
+		"go/ast":                 true,
+		"net":                    false,
+		"net/http":               false,
+		"myapp":                  true,
+		"myapp/internal/db":      true,
+		"myappx":                 false,
+		"example.com":            true,
+		"example.com/lib":        true,
+		"example.com/legacy":     false,
+		"example.com/legacy/old": false,
+		"github.com/user/repo":   false,
+	}
+	for path, expected := range cases {
+		if filter.Allows(path) != expected {
+			t.Errorf("Allows(%q) must be %v", path, expected)
+		}
+	}
+
+	// modules without a dot in the path are not in the standard library
+	filter = contracts.Filter{Exclude: []string{"std"}}
+	for _, path := range []string{"myapp", "myapp/cmd", "p"} {
+		if !filter.Allows(path) {
+			t.Errorf("%q must not be excluded as a standard library package", path)
+		}
+	}
+
+	// patterns from flags are compiled when parsed
+	filter = contracts.Filter{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	filter.AddFlags(fs)
+	err := fs.Parse([]string{"-include", "myapp/...,fmt", "-exclude", "myapp/internal/..."})
+	if err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	cases = map[string]bool{
+		"fmt":               true,
+		"myapp":             true,
+		"myapp/cmd":         true,
+		"myapp/internal/db": false,
+		"go/ast":            false,
+	}
+	for path, expected := range cases {
+		if filter.Allows(path) != expected {
+			t.Errorf("Allows(%q) must be %v", path, expected)
+		}
+	}
+}
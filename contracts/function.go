@@ -3,56 +3,199 @@ package contracts
 import (
 	"fmt"
 	"go/ast"
+	"go/constant"
+	"go/token"
 	"go/types"
 
 	"github.com/traefik/yaegi/interp"
-	"github.com/traefik/yaegi/stdlib"
 )
 
 type Function struct {
-	Args      []string
+	Args      []string // names of the function arguments
+	Types     []string // types of the function arguments, empty if not supported
+	Variadic  bool     // the last argument is variadic
 	Contracts []Contract
 }
 
 func (*Function) AFact() {}
 
-func functionFromAST(nFunc *ast.FuncDecl, info *types.Info) *Function {
+func (fn *Function) String() string {
+	return fmt.Sprintf("contracts: %d", len(fn.Contracts))
+}
+
+// exported returns a copy of the function to be used in other packages.
+//
+// Positions are reset because they are meaningless outside of the current package
+// when facts are serialized, like when running as a vet tool.
+func (fn *Function) exported() *Function {
+	contracts := make([]Contract, 0, len(fn.Contracts))
+	for _, c := range fn.Contracts {
+		c.Pos = token.NoPos
+		chain := make([]Call, 0, len(c.Chain))
+		for _, call := range c.Chain {
+			call.Pos = token.NoPos
+			chain = append(chain, call)
+		}
+		c.Chain = chain
+		contracts = append(contracts, c)
+	}
+	return &Function{fn.Args, fn.Types, fn.Variadic, contracts}
+}
+
+// AnalyzedPackage is a package fact marking that contracts
+// of all functions in the package are exported as facts.
+type AnalyzedPackage struct {
+	Functions int // number of functions with contracts
+}
+
+func (*AnalyzedPackage) AFact() {}
+
+func (p *AnalyzedPackage) String() string {
+	return fmt.Sprintf("functions with contracts: %d", p.Functions)
+}
+
+// extractor holds everything needed to extract contracts from the AST.
+type extractor struct {
+	info        *types.Info
+	fset        *token.FileSet
+	pure        map[string]struct{}             // full names of functions that contracts may call
+	terminators map[string]struct{}             // full names of functions that act like panic
+	sentinels   map[*types.Var]ast.Expr         // package-level variables and their values
+	constMaps   map[*types.Var][]constant.Value // read-only package-level maps and their keys
+	subst       map[types.Object]binding        // variables to be replaced by their values
+	args        map[types.Object]struct{}       // arguments of the function being analyzed
+	decls       map[*types.Func]*ast.FuncDecl   // all functions defined in the package
+	known       map[*types.Func]*extracted      // already analyzed functions
+	imported    Result                          // contracts of functions from other packages
+}
+
+// functionFromAST extracts contracts of the function.
+//
+// The second result value is true if the function does nothing but checking contracts,
+// so that calling it doesn't affect contracts that go after the call.
+func (e extractor) functionFromAST(nFunc *ast.FuncDecl) (*Function, bool) {
 	if nFunc.Body == nil { // should be unreachable, the caller also checks that
-		return nil
+		return nil, false
 	}
 
-	args := getFuncArgs(nFunc)
+	args, argTypes := getFuncArgs(nFunc, e.info)
 	if len(args) == 0 { // functions without arguments can't have pre-conditions
-		return nil
+		return nil, false
 	}
 
+	e.args = e.params(nFunc)
+	// names of arguments that might be changed by the code before the current statement
+	tainted := make(map[string]struct{})
 	contracts := make([]Contract, 0)
-	for _, stmt := range nFunc.Body.List {
-		contract, err := contractFromAST(stmt, info)
-		if err != nil {
-			// We assume that contracts go before any other code in the function.
-			// If we don't do that, the function might modify the argument value
-			// or break early before the contract. So, the contract that we check
-			// might be actually unreachable or different in the runtime.
+	onlyGuards := true
+	stmts := nFunc.Body.List
+	for i := 0; i < len(stmts); i++ {
+		stmt := stmts[i]
+		found, isGuard := e.contractsFromAST(stmt)
+		// `err := check(x)` followed by `if err != nil { return err }`
+		if i+1 < len(stmts) {
+			nIf, ok := stmts[i+1].(*ast.IfStmt)
+			if ok && nIf.Init == nil {
+				pairFound, pairIsGuard := e.contractsFromErrCheck(stmt, nIf)
+				if len(pairFound) != 0 {
+					found, isGuard = pairFound, pairIsGuard
+					stmt = &ast.BlockStmt{List: stmts[i : i+2]}
+					i++
+				}
+			}
+		}
+		for _, contract := range found {
+			if !readsAny(contract, tainted) {
+				contracts = append(contracts, contract)
+			}
+		}
+		if isGuard {
+			continue
+		}
+		isLast := i == len(stmts)-1
+		onlyGuards = onlyGuards && isLast && returnsNil(stmt)
+		// Other code may go before contracts as long as it doesn't
+		// break early or recover from panics. Otherwise, the contracts
+		// that we check might be actually unreachable in the runtime.
+		if mayEscape(stmt) || e.mayPanic(stmt) || e.recovers(stmt) {
 			break
 		}
-		contracts = append(contracts, *contract)
+		// Contracts that read arguments modified by the code before them
+		// (including the data they refer to, like map elements)
+		// might be different in the runtime, so we skip them.
+		for obj := range e.args {
+			if e.mutates(stmt, map[types.Object]struct{}{obj: {}}) || e.mutatesContents(stmt, obj) {
+				tainted[obj.Name()] = struct{}{}
+			}
+		}
 	}
 	if len(contracts) == 0 { // we're not interested in functions without contracts
-		return nil
+		return nil, false
+	}
+	variadic := len(nFunc.Type.Params.List) != 0 &&
+		isEllipsis(nFunc.Type.Params.List[len(nFunc.Type.Params.List)-1].Type)
+	return &Function{args, argTypes, variadic, contracts}, onlyGuards
+}
+
+// returnsNil checks if the statement is a return without results or with only nils.
+func returnsNil(stmt ast.Stmt) bool {
+	nRet, ok := stmt.(*ast.ReturnStmt)
+	if !ok {
+		return false
+	}
+	for _, nExpr := range nRet.Results {
+		nIdent, ok := unparen(nExpr).(*ast.Ident)
+		if !ok || nIdent.Name != "nil" {
+			return false
+		}
+	}
+	return true
+}
+
+// params returns all named arguments of the function, including the receiver.
+func (e extractor) params(nFunc *ast.FuncDecl) map[types.Object]struct{} {
+	res := make(map[types.Object]struct{})
+	fields := nFunc.Type.Params.List
+	if nFunc.Recv != nil {
+		// copy the list, so that appending doesn't change the AST
+		fields = append(append([]*ast.Field{}, fields...), nFunc.Recv.List...)
+	}
+	for _, field := range fields {
+		for _, nIdent := range field.Names {
+			obj := e.info.Defs[nIdent]
+			if obj != nil {
+				res[obj] = struct{}{}
+			}
+		}
+	}
+	return res
+}
+
+// readsAny checks if the contract condition uses any of the given names.
+func readsAny(contract Contract, names map[string]struct{}) bool {
+	for _, name := range contract.Names {
+		if _, found := names[name]; found {
+			return true
+		}
 	}
-	return &Function{args, contracts}
+	return false
 }
 
 // MapArgs converts list of expressions to strings and maps them to function argument names.
+//
+// The variadic argument is a slice of all the remaining expressions,
+// and so its value is never mapped.
 func (fn Function) MapArgs(exprs []ast.Expr, info *types.Info) map[string]string {
 	if len(fn.Args) != len(exprs) {
 		return nil
 	}
 	res := make(map[string]string)
 	for i, arg := range fn.Args {
+		if fn.Variadic && i == len(fn.Args)-1 {
+			break
+		}
 		expr := exprs[i]
-		strExpr, names, err := expr2string(expr, info)
+		strExpr, names, err := extractor{info: info}.expr2string(expr)
 		if len(names) != 0 { // argument definition must not have any unbound variables
 			continue
 		}
@@ -67,6 +210,8 @@ func (fn Function) MapArgs(exprs []ast.Expr, info *types.Info) map[string]string
 // Validate chackes all contracts for a function using the given function arguments.
 //
 // If a contract is violated, that contract is returned.
+// Arguments missing in vars are unknown. A contract that uses them is violated
+// only if its condition is true no matter what their values are.
 //
 // Possible return values:
 //
@@ -79,54 +224,91 @@ func (fn Function) MapArgs(exprs []ast.Expr, info *types.Info) map[string]string
 // if we have a meaningful error to show for another contract.
 // That allows the analyzer to safely ignore contract errors.
 func (fn Function) Validate(vars map[string]string) (*Contract, error) {
+	return fn.ValidateRanges(vars, nil)
+}
+
+// ValidateRanges is the same as Validate but also uses known ranges
+// of values for arguments that don't have a known exact value.
+func (fn Function) ValidateRanges(vars map[string]string, ranges map[string]Range) (*Contract, error) {
 	// prepare interpreter
 	interpreter := interp.New(interp.Options{})
-	err := interpreter.Use(stdlib.Symbols)
+	err := interpreter.Use(pureSymbols(fn.funcs()))
 	if err != nil {
 		return nil, fmt.Errorf("use stdlib: %v", err)
 	}
 	interpreter.ImportUsed()
-	for name, val := range vars {
+	// Arguments that cannot be set are treated as unknown,
+	// so that contracts that don't use them are still checked.
+	var firstErr error = nil
+	known := make(map[string]string, len(vars))
+	for i, name := range fn.Args {
+		val, defined := vars[name]
+		if !defined {
+			continue
+		}
+		// declare the variable with the argument type, so that untyped constants
+		// don't get the default type and behave the same as in the function
 		expr := fmt.Sprintf("%s := %s", name, val)
+		if i < len(fn.Types) && fn.Types[i] != "" {
+			expr = fmt.Sprintf("var %s %s = %s", name, fn.Types[i], val)
+		}
 		_, err = interpreter.Eval(expr)
 		if err != nil {
-			return nil, fmt.Errorf("set value for %s: %v", name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("set value for %s: %v", name, err)
+			}
+			continue
 		}
+		known[name] = val
 	}
 
 	// check all contracts
-	var firstErr error = nil
 	for _, c := range fn.Contracts {
-		if !c.allDefined(vars) {
-			continue
-		}
-		valid, err := c.validate(interpreter)
+		res, err := c.evaluate(interpreter, known, ranges)
 		if err != nil {
 			if firstErr == nil {
 				firstErr = fmt.Errorf("run `%s`: %v", c.Condition, err)
 			}
 			continue
 		}
-		if !valid {
+		if res == isTrue {
+			c.Message = c.formatMessage(interpreter)
 			return &c, nil
 		}
 	}
 	return nil, firstErr
 }
 
-// getFuncArgs returns argument names for the given function declaration.
-func getFuncArgs(nFunc *ast.FuncDecl) []string {
+// funcs returns full names of all pure functions called by the contracts.
+func (fn Function) funcs() []string {
+	res := make([]string, 0)
+	for _, c := range fn.Contracts {
+		res = append(res, c.Funcs...)
+	}
+	return res
+}
+
+// getFuncArgs returns argument names and types for the given function declaration.
+func getFuncArgs(nFunc *ast.FuncDecl, info *types.Info) ([]string, []string) {
 	if nFunc.Type == nil {
-		return nil
+		return nil, nil
 	}
 	if nFunc.Type.Params == nil {
-		return nil
+		return nil, nil
 	}
-	res := make([]string, 0)
+	names := make([]string, 0)
+	argTypes := make([]string, 0)
 	for _, nField := range nFunc.Type.Params.List {
 		for _, nIdent := range nField.Names {
-			res = append(res, nIdent.Name)
+			names = append(names, nIdent.Name)
+			// the variadic argument type is a slice of the declared type
+			argType := ""
+			obj := info.Defs[nIdent]
+			if obj != nil && !isEllipsis(nField.Type) {
+				argType, _ = typeExpr(obj.Type())
+			}
+			argTypes = append(argTypes, argType)
 		}
 	}
-	return res
+	return names, argTypes
 }
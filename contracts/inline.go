@@ -0,0 +1,308 @@
+package contracts
+
+import (
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Call is a function call through which a contract is inherited.
+type Call struct {
+	Name string    // name of the called function
+	Pos  token.Pos // position of the contract in the called function
+}
+
+// extracted is the memoized result of extracting contracts from a function.
+type extracted struct {
+	fn         *Function
+	onlyGuards bool
+}
+
+// functionOf returns contracts of the function.
+//
+// The results for functions defined in the current package are memoized,
+// so each function is analyzed only once. For functions from other packages,
+// the already known contracts are used.
+//
+// The second result value is true if the function does nothing but checking contracts.
+func (e extractor) functionOf(obj *types.Func) (*Function, bool) {
+	res, found := e.known[obj]
+	if found {
+		return res.fn, res.onlyGuards
+	}
+	nFunc, found := e.decls[obj]
+	if !found {
+		return e.imported[obj], false
+	}
+	// mark as analyzed in advance to not loop forever on recursive calls
+	e.known[obj] = &extracted{}
+	fn, onlyGuards := e.functionFromAST(nFunc)
+	e.known[obj] = &extracted{fn, onlyGuards}
+	return fn, onlyGuards
+}
+
+// contractsFromErrCheck inherits contracts from a function called in the init statement
+// of `if err := check(x); err != nil { return err }` or in the assignment right before it.
+//
+// Since the error is returned, contracts that return an error are inherited as well.
+func (e extractor) contractsFromErrCheck(init ast.Stmt, nIf *ast.IfStmt) ([]Contract, bool) {
+	nAssign, ok := init.(*ast.AssignStmt)
+	if !ok || len(nAssign.Rhs) != 1 || nIf.Else != nil {
+		return nil, false
+	}
+	nCall, ok := unparen(nAssign.Rhs[0]).(*ast.CallExpr)
+	if !ok {
+		return nil, false
+	}
+	nErr, ok := nAssign.Lhs[len(nAssign.Lhs)-1].(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	errObj := e.info.ObjectOf(nErr)
+	if errObj == nil || !e.isNilCheck(nIf.Cond, errObj) {
+		return nil, false
+	}
+	msg, isError := e.extractMessage(nIf.Body.List, map[types.Object]struct{}{})
+	if !isError {
+		return nil, false
+	}
+	found, isGuard := e.contractsFromCall(init.Pos(), nCall, true)
+	for i := range found {
+		found[i].handledBy(msg, nErr.Name)
+	}
+	return found, isGuard
+}
+
+// handledBy updates the inherited contract with the message of the code
+// handling the error returned by the callee, like `panic(err)`
+// or `return fmt.Errorf("parse: %w", err)`.
+//
+// If the error is passed as is, the inherited message is kept.
+// If it is wrapped, the inherited message is used in place of the error.
+func (c *Contract) handledBy(msg message, errName string) {
+	if msg.text == "" {
+		if msg.panics && !c.Panics {
+			rest, found := strings.CutPrefix(c.Message, "returns ")
+			if found {
+				c.Message = "panics with " + rest
+				c.Format = ""
+				c.FormatArgs = nil
+			}
+		}
+		c.Panics = msg.panics
+		return
+	}
+	inherited := c.Message
+	c.Panics = msg.panics
+	c.Message = msg.text
+	c.Format = msg.format
+	c.FormatArgs = nil
+	if msg.format == "" {
+		return
+	}
+	unknown := make([]any, 0, len(msg.args))
+	for _, arg := range msg.args {
+		if arg.Text == errName {
+			arg = MessageArg{Text: inherited}
+		}
+		c.FormatArgs = append(c.FormatArgs, arg)
+		unknown = append(unknown, unknownArg(arg.Text))
+	}
+	c.Message = fmt.Sprintf(c.Format, unknown...)
+}
+
+// isNilCheck checks if the expression is `err != nil` for the given variable.
+func (e extractor) isNilCheck(expr ast.Expr, obj types.Object) bool {
+	nBin, ok := unparen(expr).(*ast.BinaryExpr)
+	if !ok || nBin.Op != token.NEQ {
+		return false
+	}
+	isVar := func(expr ast.Expr) bool {
+		nIdent, ok := unparen(expr).(*ast.Ident)
+		return ok && e.info.Uses[nIdent] == obj
+	}
+	isNil := func(expr ast.Expr) bool {
+		return e.info.Types[expr].IsNil()
+	}
+	return (isVar(nBin.X) && isNil(nBin.Y)) || (isNil(nBin.X) && isVar(nBin.Y))
+}
+
+// contractsFromCallStmt inherits contracts from the function called by the statement.
+//
+// The statement is a call of a guard helper, like `checkSize(w, h)`,
+// or of a wrapped function, like `n := parse(s)` or `return parse(s, 10)`.
+// Only calls of guard helpers can be treated as guards.
+func (e extractor) contractsFromCallStmt(stmt ast.Stmt) ([]Contract, bool) {
+	switch v := stmt.(type) {
+	case *ast.ExprStmt:
+		nCall, ok := unparen(v.X).(*ast.CallExpr)
+		if ok {
+			return e.contractsFromCall(v.Pos(), nCall, false)
+		}
+	case *ast.AssignStmt:
+		if len(v.Rhs) != 1 {
+			return nil, false
+		}
+		nCall, ok := unparen(v.Rhs[0]).(*ast.CallExpr)
+		if ok {
+			found, _ := e.contractsFromCall(v.Pos(), nCall, false)
+			return found, false
+		}
+	case *ast.ReturnStmt:
+		if len(v.Results) != 1 {
+			return nil, false
+		}
+		nCall, ok := unparen(v.Results[0]).(*ast.CallExpr)
+		if ok {
+			found, _ := e.contractsFromCall(v.Pos(), nCall, true)
+			return found, false
+		}
+	}
+	return nil, false
+}
+
+// contractsFromCall inherits contracts from the called function.
+//
+// Only contracts that use arguments passed straight from the arguments
+// of the function being analyzed (possibly, in a different order)
+// with the same type or constants are inherited. Names of the callee arguments in the contracts
+// are replaced by the caller arguments or the constant values.
+//
+// If the error returned by the callee is not returned by the caller,
+// only contracts that panic are inherited.
+//
+// The second result value is true if the called function does nothing
+// but checking contracts, and so the call can be treated as a guard.
+func (e extractor) contractsFromCall(pos token.Pos, nCall *ast.CallExpr, returnsErr bool) ([]Contract, bool) {
+	callee, ok := typeutil.Callee(e.info, nCall).(*types.Func)
+	if !ok {
+		return nil, false
+	}
+	sig := callee.Type().(*types.Signature)
+	if sig.Recv() != nil || sig.Variadic() || sig.Params().Len() != len(nCall.Args) {
+		return nil, false
+	}
+	fn, onlyGuards := e.functionOf(callee)
+	if fn == nil {
+		return nil, false
+	}
+
+	// Arguments are renamed only if the value is the same in both functions.
+	// For example, a nil pointer passed as an interface is not nil anymore.
+	renames := make(map[string]string)
+	for i, nArg := range nCall.Args {
+		param := sig.Params().At(i)
+		_, isInterface := param.Type().Underlying().(*types.Interface)
+		folded := e.foldConstant(nArg)
+		if folded != "" {
+			// Constants are converted to the parameter type, so that
+			// the arithmetic in the contract overflows the same way.
+			typeName, err := typeExpr(param.Type())
+			if err == nil && !isInterface {
+				renames[param.Name()] = typeName + "(" + folded + ")"
+			}
+			continue
+		}
+		nIdent, ok := unparen(nArg).(*ast.Ident)
+		if !ok {
+			continue
+		}
+		obj := e.info.Uses[nIdent]
+		if _, isArg := e.args[obj]; isArg && types.Identical(obj.Type(), param.Type()) {
+			renames[param.Name()] = nIdent.Name
+		}
+	}
+
+	contracts := make([]Contract, 0, len(fn.Contracts))
+	for _, c := range fn.Contracts {
+		inherited, ok := c.rename(renames)
+		if !ok || !(c.Panics || returnsErr) {
+			onlyGuards = false
+			continue
+		}
+		inherited.Pos = pos
+		inherited.Chain = append([]Call{{callee.Name(), c.Pos}}, c.Chain...)
+		contracts = append(contracts, inherited)
+	}
+	return contracts, onlyGuards
+}
+
+// rename returns a copy of the contract with variables renamed.
+//
+// If any of the variables used by the condition is not renamed, returns false.
+func (c Contract) rename(renames map[string]string) (Contract, bool) {
+	names := make([]string, 0, len(c.Names))
+	for _, name := range c.Names {
+		newName, found := renames[name]
+		if !found {
+			return c, false
+		}
+		if token.IsIdentifier(newName) {
+			names = append(names, newName)
+		}
+	}
+	if len(names) == 0 { // the condition is static, all arguments are constants
+		return c, false
+	}
+	c.Names = names
+	c.Condition = renameVars(c.Condition, renames)
+	if c.Format == "" {
+		return c, true
+	}
+	args := make([]MessageArg, 0, len(c.FormatArgs))
+	unknown := make([]any, 0, len(c.FormatArgs))
+	for _, arg := range c.FormatArgs {
+		arg.Expr = renameVars(arg.Expr, renames)
+		arg.Text = renameVars(arg.Text, renames)
+		args = append(args, arg)
+		unknown = append(unknown, unknownArg(arg.Text))
+	}
+	c.FormatArgs = args
+	c.Message = fmt.Sprintf(c.Format, unknown...)
+	return c, true
+}
+
+// renameVars renames identifiers in the Go expression.
+//
+// Selected names, like fields and functions from other packages,
+// are left as is. The formatting of the expression is preserved.
+func renameVars(expr string, renames map[string]string) string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(expr))
+	var s scanner.Scanner
+	s.Init(file, []byte(expr), nil, 0)
+	var res strings.Builder
+	last := 0
+	prev := token.ILLEGAL
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		newName, found := renames[lit]
+		if tok == token.IDENT && prev != token.PERIOD && found {
+			offset := file.Offset(pos)
+			res.WriteString(expr[last:offset])
+			res.WriteString(newName)
+			last = offset + len(lit)
+		}
+		prev = tok
+	}
+	res.WriteString(expr[last:])
+	return res.String()
+}
+
+// Trace returns names of the functions through which the contract is inherited,
+// like "Parse -> validate". Returns an empty string if the contract isn't inherited.
+func (c Contract) Trace() string {
+	names := make([]string, 0, len(c.Chain))
+	for _, call := range c.Chain {
+		names = append(names, call.Name)
+	}
+	return strings.Join(names, " -> ")
+}
@@ -0,0 +1,289 @@
+package contracts
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Terminators is the default list of functions that never return, similar to panic.
+//
+// The names are in the same format as returned by [types.Func.FullName].
+var Terminators = []string{
+	"log.Fatal",
+	"log.Fatalf",
+	"log.Fatalln",
+	"log.Panic",
+	"log.Panicf",
+	"log.Panicln",
+	"(*log.Logger).Fatal",
+	"(*log.Logger).Fatalf",
+	"(*log.Logger).Fatalln",
+	"(*log.Logger).Panic",
+	"(*log.Logger).Panicf",
+	"(*log.Logger).Panicln",
+	"os.Exit",
+	"(*testing.common).FailNow",
+	"(*testing.common).Fatal",
+	"(*testing.common).Fatalf",
+}
+
+// MessageArg is an argument of a formatted contract message.
+type MessageArg struct {
+	Expr string // valid Go-syntax expression, empty if it's not safe to evaluate
+	Text string // source code of the argument, shown if the value is not known
+}
+
+// unknownArg is a format argument with unknown value.
+//
+// It is shown as its source code no matter what formatting verb is used.
+type unknownArg string
+
+func (arg unknownArg) Format(f fmt.State, verb rune) {
+	_, _ = f.Write([]byte(arg))
+}
+
+// message is a contract error message extracted from the AST.
+type message struct {
+	text   string       // message with all format arguments shown as source code
+	format string       // format string if the message is formatted
+	args   []MessageArg // format arguments
+	panics bool         // the code panics or exits instead of returning an error
+}
+
+// extractMessage extracts error message for the contract.
+//
+// The first result value is the extraccted error message
+// which might be empty if it cannot be extracted.
+//
+// The second result value tells if the given code block
+// is an error of some kind typical for a contract.
+// A contract must either panic or return an error as one of the return values.
+// It may be preceded by other statements, like logging, if they cannot
+// return early or change the guarded variables.
+func (e extractor) extractMessage(body []ast.Stmt, guarded map[types.Object]struct{}) (message, bool) {
+	if len(body) == 0 {
+		return message{}, false
+	}
+	for _, nStmt := range body[:len(body)-1] {
+		if mayEscape(nStmt) || e.mutates(nStmt, guarded) || e.recovers(nStmt) {
+			return message{}, false
+		}
+	}
+	nStmt := body[len(body)-1]
+
+	// check if it's panic
+	nExpr, ok := nStmt.(*ast.ExprStmt)
+	if ok {
+		msg, isError := e.extractMessageFromPanic(nExpr)
+		msg.panics = true
+		return msg, isError
+	}
+
+	nRet, ok := nStmt.(*ast.ReturnStmt)
+	if ok {
+		return e.extractMessageFromReturn(nRet)
+	}
+	return message{}, false
+}
+
+func (e extractor) extractMessageFromPanic(nExpr *ast.ExprStmt) (message, bool) {
+	// check if the expression is a "panic"
+	if nExpr.X == nil {
+		return message{}, false
+	}
+	nCall, ok := nExpr.X.(*ast.CallExpr)
+	if !ok {
+		return message{}, false
+	}
+	if !e.isBuiltin(nCall.Fun, "panic") {
+		return e.extractMessageFromTerminator(nCall)
+	}
+	if len(nCall.Args) != 1 {
+		return message{}, false
+	}
+
+	// extract the error message
+	return e.messageFromError(nCall.Args[0], "panics with"), true
+}
+
+// extractMessageFromTerminator extracts the message from a call of a function
+// that never returns, like log.Fatal or os.Exit.
+//
+// The message is the first argument if it is a string. If the function name
+// ends with "f", like log.Fatalf, the string is a format for other arguments.
+func (e extractor) extractMessageFromTerminator(nCall *ast.CallExpr) (message, bool) {
+	callee, ok := typeutil.Callee(e.info, nCall).(*types.Func)
+	if !ok {
+		return message{}, false
+	}
+	_, isTerminator := e.terminators[callee.FullName()]
+	if !isTerminator {
+		return message{}, false
+	}
+	if len(nCall.Args) == 0 || nCall.Ellipsis.IsValid() {
+		return message{}, true
+	}
+	argType, ok := e.info.Types[nCall.Args[0]]
+	if !ok {
+		return message{}, true
+	}
+	basic, isBasic := argType.Type.Underlying().(*types.Basic)
+	if !isBasic || basic.Info()&types.IsString == 0 {
+		return message{}, true
+	}
+	if strings.HasSuffix(callee.Name(), "f") {
+		return e.messageFromFormat(nCall.Args[0], nCall.Args[1:]), true
+	}
+	return e.messageFromExpr(nCall.Args[0]), true
+}
+
+func (e extractor) extractMessageFromReturn(nRet *ast.ReturnStmt) (message, bool) {
+	if nRet.Results == nil {
+		return message{}, false
+	}
+	for _, nExpr := range nRet.Results {
+		exprType, ok := e.info.Types[nExpr]
+		if !ok {
+			continue
+		}
+		if exprType.IsNil() {
+			continue
+		}
+		if exprType.Type.String() == "error" || implementsError(exprType.Type) {
+			return e.messageFromError(nExpr, "returns"), true
+		}
+	}
+	return message{}, false
+}
+
+// messageFromError extracts the message from an error value.
+//
+// Sentinel errors and values of custom error types are named in the message,
+// so that it's clear which error the function will produce.
+// The verb describes what the function does with the error, like "returns".
+func (e extractor) messageFromError(expr ast.Expr, verb string) message {
+	exprType, ok := e.info.Types[expr]
+	if !ok || !implementsError(exprType.Type) {
+		return e.messageFromExpr(expr)
+	}
+	switch v := unparen(expr).(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		obj, ok := e.objectOf(v).(*types.Var)
+		if !ok || obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+			return e.messageFromExpr(expr)
+		}
+		text := fmt.Sprintf("%s %s", verb, types.ExprString(v))
+		nValue, found := e.sentinels[obj]
+		if found {
+			inner := e.messageFromExpr(nValue)
+			if inner.text != "" {
+				text = fmt.Sprintf("%s (%q)", text, inner.text)
+			}
+		}
+		return message{text: text}
+	case *ast.CompositeLit:
+		return message{text: fmt.Sprintf("%s %s", verb, e.source(v))}
+	case *ast.UnaryExpr:
+		_, isLit := v.X.(*ast.CompositeLit)
+		if v.Op == token.AND && isLit {
+			return message{text: fmt.Sprintf("%s %s", verb, e.source(v))}
+		}
+	}
+	return e.messageFromExpr(expr)
+}
+
+// objectOf returns the object referred to by the identifier or qualified identifier.
+func (e extractor) objectOf(expr ast.Expr) types.Object {
+	switch v := expr.(type) {
+	case *ast.Ident:
+		return e.info.Uses[v]
+	case *ast.SelectorExpr:
+		return e.info.Uses[v.Sel]
+	}
+	return nil
+}
+
+// source returns the source code of the expression.
+func (e extractor) source(expr ast.Expr) string {
+	if e.fset == nil {
+		return types.ExprString(expr)
+	}
+	var buf bytes.Buffer
+	err := printer.Fprint(&buf, e.fset, expr)
+	if err != nil {
+		return types.ExprString(expr)
+	}
+	return buf.String()
+}
+
+// implementsError checks if values of the given type can be used as an error.
+func implementsError(t types.Type) bool {
+	errType := types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+	return types.Implements(t, errType)
+}
+
+// messageFromExpr extracts the message from a constant or an error constructor.
+//
+// Supported constructors are errors.New, fmt.Errorf, and fmt.Sprintf.
+// The message is empty if it cannot be extracted.
+func (e extractor) messageFromExpr(expr ast.Expr) message {
+	exprType, ok := e.info.Types[expr]
+	if ok && exprType.Value != nil {
+		if exprType.Value.Kind() == constant.String {
+			return message{text: constant.StringVal(exprType.Value)}
+		}
+		return message{text: FormatConstant(exprType.Value)}
+	}
+
+	nCall, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return message{}
+	}
+	callee, ok := typeutil.Callee(e.info, nCall).(*types.Func)
+	if !ok || len(nCall.Args) == 0 || nCall.Ellipsis.IsValid() {
+		return message{}
+	}
+	switch callee.FullName() {
+	case "errors.New":
+		return e.messageFromExpr(nCall.Args[0])
+	case "fmt.Errorf", "fmt.Sprintf":
+		return e.messageFromFormat(nCall.Args[0], nCall.Args[1:])
+	}
+	return message{}
+}
+
+// messageFromFormat extracts the message from a format string and its arguments.
+func (e extractor) messageFromFormat(nFormat ast.Expr, nArgs []ast.Expr) message {
+	format := e.messageFromExpr(nFormat)
+	if format.text == "" || format.format != "" {
+		return message{}
+	}
+	// %w is supported only by fmt.Errorf and the message is rendered with fmt.Sprintf
+	fmtString := strings.ReplaceAll(format.text, "%w", "%v")
+
+	args := make([]MessageArg, 0, len(nArgs))
+	unknown := make([]any, 0, len(nArgs))
+	for _, nArg := range nArgs {
+		text := types.ExprString(nArg)
+		arg := MessageArg{Text: text}
+		expr, _, err := e.expr2string(nArg)
+		if err == nil {
+			arg.Expr = expr
+		}
+		args = append(args, arg)
+		unknown = append(unknown, unknownArg(text))
+	}
+	return message{
+		text:   fmt.Sprintf(fmtString, unknown...),
+		format: fmtString,
+		args:   args,
+	}
+}
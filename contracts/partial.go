@@ -0,0 +1,241 @@
+package contracts
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"math"
+	"reflect"
+
+	"github.com/traefik/yaegi/interp"
+)
+
+// tristate is a result of evaluating a condition when some variables are unknown.
+type tristate int
+
+const (
+	unknown tristate = iota
+	isFalse
+	isTrue
+)
+
+func (t tristate) not() tristate {
+	switch t {
+	case isFalse:
+		return isTrue
+	case isTrue:
+		return isFalse
+	}
+	return unknown
+}
+
+// evaluate evaluates the contract condition using the known variables.
+//
+// If some of the variables are unknown, the condition is evaluated partially,
+// with short-circuiting of && and || operators. For example, `x == 0 || y < 0`
+// is true if y is known to be negative, no matter what x is.
+//
+// For unknown variables, comparisons with known values
+// are evaluated using the known ranges of their values.
+func (c Contract) evaluate(
+	interpreter *interp.Interpreter,
+	vars map[string]string,
+	ranges map[string]Range,
+) (tristate, error) {
+	if c.allDefined(vars) {
+		valid, err := c.validate(interpreter)
+		if err != nil {
+			return unknown, err
+		}
+		if valid {
+			return isFalse, nil
+		}
+		return isTrue, nil
+	}
+	missing := make(map[string]struct{})
+	known := false
+	for _, name := range c.Names {
+		_, defined := vars[name]
+		if !defined {
+			missing[name] = struct{}{}
+		}
+		_, ranged := ranges[name]
+		known = known || defined || ranged
+	}
+	if !known { // nothing is known, don't bother parsing
+		return unknown, nil
+	}
+	fset := token.NewFileSet()
+	expr, err := parser.ParseExprFrom(fset, "", c.Condition, 0)
+	if err != nil {
+		return unknown, fmt.Errorf("parse condition: %v", err)
+	}
+	p := partial{interpreter, fset, c.Condition, missing, ranges, nil}
+	res := p.eval(expr)
+	if res == unknown && p.err != nil {
+		return unknown, p.err
+	}
+	return res, nil
+}
+
+// partial is a partial evaluator of conditions.
+type partial struct {
+	interpreter *interp.Interpreter
+	fset        *token.FileSet
+	source      string              // the condition source code
+	missing     map[string]struct{} // variables with unknown values
+	ranges      map[string]Range    // known ranges of the unknown variables
+	err         error               // the first error occurred during evaluation
+}
+
+func (p *partial) eval(expr ast.Expr) tristate {
+	switch v := expr.(type) {
+	case *ast.ParenExpr:
+		return p.eval(v.X)
+	case *ast.UnaryExpr:
+		if v.Op == token.NOT {
+			return p.eval(v.X).not()
+		}
+	case *ast.BinaryExpr:
+		switch v.Op {
+		case token.LAND:
+			left := p.eval(v.X)
+			if left == isFalse {
+				return isFalse
+			}
+			right := p.eval(v.Y)
+			if right == isFalse {
+				return isFalse
+			}
+			if left == isTrue && right == isTrue {
+				return isTrue
+			}
+			return unknown
+		case token.LOR:
+			left := p.eval(v.X)
+			if left == isTrue {
+				return isTrue
+			}
+			right := p.eval(v.Y)
+			if right == isTrue {
+				return isTrue
+			}
+			if left == isFalse && right == isFalse {
+				return isFalse
+			}
+			return unknown
+		}
+	}
+	return p.evalLeaf(expr)
+}
+
+// evalLeaf evaluates the expression if it doesn't use unknown variables.
+func (p *partial) evalLeaf(expr ast.Expr) tristate {
+	if p.usesMissing(expr) {
+		return p.evalRange(expr)
+	}
+	res, ok := p.evalExpr(expr)
+	if !ok {
+		return unknown
+	}
+	value, isBool := res.Interface().(bool)
+	if !isBool {
+		return unknown
+	}
+	if value {
+		return isTrue
+	}
+	return isFalse
+}
+
+// evalRange evaluates comparison of an unknown variable with a known value,
+// like `x < 10`, using the known range of the variable values.
+func (p *partial) evalRange(expr ast.Expr) tristate {
+	nBin, ok := expr.(*ast.BinaryExpr)
+	if !ok {
+		return unknown
+	}
+	op := nBin.Op
+	nVar, ok := unparen(nBin.X).(*ast.Ident)
+	other := nBin.Y
+	if !ok || !p.hasRange(nVar) {
+		nVar, ok = unparen(nBin.Y).(*ast.Ident)
+		other = nBin.X
+		op = Mirror(op)
+	}
+	if !ok || !p.hasRange(nVar) || p.usesMissing(other) {
+		return unknown
+	}
+	res, ok := p.evalExpr(other)
+	if !ok {
+		return unknown
+	}
+	value := constantOf(res)
+	if value == nil {
+		return unknown
+	}
+	return p.ranges[nVar.Name].compare(op, value)
+}
+
+func (p *partial) hasRange(nIdent *ast.Ident) bool {
+	_, found := p.ranges[nIdent.Name]
+	return found
+}
+
+// evalExpr evaluates the expression that uses only known variables.
+func (p *partial) evalExpr(expr ast.Expr) (reflect.Value, bool) {
+	start := p.fset.Position(expr.Pos()).Offset
+	end := p.fset.Position(expr.End()).Offset
+	res, err := safeEval(p.interpreter, p.source[start:end])
+	if err != nil {
+		if p.err == nil {
+			p.err = fmt.Errorf("evaluate `%s`: %v", p.source[start:end], err)
+		}
+		return res, false
+	}
+	return res, res.IsValid() && res.CanInterface()
+}
+
+// constantOf converts the numeric value into a constant, returns nil for other values.
+func constantOf(value reflect.Value) constant.Value {
+	switch {
+	case value.CanInt():
+		return constant.MakeInt64(value.Int())
+	case value.CanUint():
+		return constant.MakeUint64(value.Uint())
+	case value.CanFloat():
+		f := value.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil
+		}
+		return constant.MakeFloat64(f)
+	}
+	return nil
+}
+
+// usesMissing checks if the expression uses any of the unknown variables.
+func (p *partial) usesMissing(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch v := node.(type) {
+		case *ast.SelectorExpr:
+			// the selected name is a field or a function, not a variable
+			ast.Inspect(v.X, func(node ast.Node) bool {
+				nIdent, ok := node.(*ast.Ident)
+				if ok {
+					_, missing := p.missing[nIdent.Name]
+					found = found || missing
+				}
+				return !found
+			})
+			return false
+		case *ast.Ident:
+			_, missing := p.missing[v.Name]
+			found = found || missing
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,117 @@
+package contracts
+
+import (
+	"go/types"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// PureFuncs is the default list of functions that contracts are allowed to call.
+//
+// All of them are from the standard library, have no side effects,
+// and don't panic on any input. The names are in the same format
+// as returned by [types.Func.FullName].
+var PureFuncs = []string{
+	"math.Abs",
+	"math.Ceil",
+	"math.Floor",
+	"math.IsInf",
+	"math.IsNaN",
+	"math.Max",
+	"math.Min",
+	"math.Signbit",
+	"math.Trunc",
+	"math/bits.LeadingZeros",
+	"math/bits.Len",
+	"math/bits.OnesCount",
+	"math/bits.TrailingZeros",
+	"path.IsAbs",
+	"strings.Contains",
+	"strings.ContainsAny",
+	"strings.ContainsRune",
+	"strings.Count",
+	"strings.EqualFold",
+	"strings.HasPrefix",
+	"strings.HasSuffix",
+	"strings.Index",
+	"strings.IndexAny",
+	"strings.IndexByte",
+	"strings.IndexRune",
+	"strings.LastIndex",
+	"strings.ToLower",
+	"strings.ToUpper",
+	"strings.TrimPrefix",
+	"strings.TrimSpace",
+	"strings.TrimSuffix",
+	"unicode.IsControl",
+	"unicode.IsDigit",
+	"unicode.IsLetter",
+	"unicode.IsLower",
+	"unicode.IsNumber",
+	"unicode.IsPrint",
+	"unicode.IsPunct",
+	"unicode.IsSpace",
+	"unicode.IsUpper",
+	"unicode/utf8.RuneCountInString",
+	"unicode/utf8.RuneLen",
+	"unicode/utf8.ValidRune",
+	"unicode/utf8.ValidString",
+}
+
+// isPure checks if the function is in the allow-list and can be called by the interpreter.
+func (e extractor) isPure(fn *types.Func) bool {
+	if fn.Type().(*types.Signature).Recv() != nil { // methods aren't supported
+		return false
+	}
+	_, allowed := e.pure[fn.FullName()]
+	if !allowed {
+		return false
+	}
+	_, found := lookupSymbol(fn.FullName())
+	return found
+}
+
+// lookupSymbol finds the stdlib function by its full name, like "strings.HasPrefix".
+func lookupSymbol(fullName string) (reflect.Value, bool) {
+	dot := strings.LastIndex(fullName, ".")
+	if dot == -1 {
+		return reflect.Value{}, false
+	}
+	pkgPath := fullName[:dot]
+	name := fullName[dot+1:]
+	// symbols are grouped by keys like "unicode/utf8/utf8"
+	for key, symbols := range stdlib.Symbols {
+		if path.Dir(key) != pkgPath {
+			continue
+		}
+		symbol, found := symbols[name]
+		return symbol, found
+	}
+	return reflect.Value{}, false
+}
+
+// pureSymbols returns symbols of the given functions in the format expected by the interpreter.
+//
+// The interpreter has access only to the functions that contracts call
+// and nothing else from the standard library.
+func pureSymbols(funcs []string) interp.Exports {
+	exports := make(interp.Exports)
+	for _, fullName := range funcs {
+		symbol, found := lookupSymbol(fullName)
+		if !found {
+			continue
+		}
+		dot := strings.LastIndex(fullName, ".")
+		pkgPath := fullName[:dot]
+		key := pkgPath + "/" + path.Base(pkgPath)
+		if exports[key] == nil {
+			exports[key] = make(map[string]reflect.Value)
+		}
+		exports[key][fullName[dot+1:]] = symbol
+	}
+	return exports
+}
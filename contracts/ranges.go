@@ -0,0 +1,187 @@
+package contracts
+
+import (
+	"go/constant"
+	"go/token"
+)
+
+// Range is what is known about a numeric argument value when the exact value isn't known.
+//
+// For example, if the function is called inside of `if n > 0 { ... }`,
+// the argument n is known to be in the range (0, +inf).
+type Range struct {
+	Min, Max         constant.Value   // bounds of the range, nil if unbounded
+	MinOpen, MaxOpen bool             // the bound itself is not in the range
+	Excluded         []constant.Value // values known to be not in the range
+	Integer          bool             // the value is an integer
+}
+
+// Constrain returns the range narrowed by the condition `x op value` being true.
+func (r Range) Constrain(op token.Token, value constant.Value) Range {
+	switch op {
+	case token.LSS, token.LEQ:
+		open := op == token.LSS
+		if r.Max == nil || constant.Compare(value, token.LSS, r.Max) {
+			r.Max, r.MaxOpen = value, open
+		} else if constant.Compare(value, token.EQL, r.Max) {
+			r.MaxOpen = r.MaxOpen || open
+		}
+	case token.GTR, token.GEQ:
+		open := op == token.GTR
+		if r.Min == nil || constant.Compare(value, token.GTR, r.Min) {
+			r.Min, r.MinOpen = value, open
+		} else if constant.Compare(value, token.EQL, r.Min) {
+			r.MinOpen = r.MinOpen || open
+		}
+	case token.EQL:
+		r = r.Constrain(token.GEQ, value).Constrain(token.LEQ, value)
+	case token.NEQ:
+		r.Excluded = append(append([]constant.Value{}, r.Excluded...), value)
+	}
+	return r.normalize()
+}
+
+// normalize makes integer bounds closed and moves them past excluded values.
+func (r Range) normalize() Range {
+	if !r.Integer {
+		return r
+	}
+	one := constant.MakeInt64(1)
+	for changed := true; changed; {
+		changed = false
+		if r.Min != nil {
+			r.Min = toInt(r.Min)
+			if r.MinOpen || r.excludes(r.Min) {
+				r.Min, r.MinOpen, changed = constant.BinaryOp(r.Min, token.ADD, one), false, true
+			}
+		}
+		if r.Max != nil {
+			r.Max = toInt(r.Max)
+			if r.MaxOpen || r.excludes(r.Max) {
+				r.Max, r.MaxOpen, changed = constant.BinaryOp(r.Max, token.SUB, one), false, true
+			}
+		}
+		if r.isEmpty() {
+			return r
+		}
+	}
+	return r
+}
+
+// toInt converts the value to an integer if it's an integer value of another kind, like 2.0.
+func toInt(value constant.Value) constant.Value {
+	res := constant.ToInt(value)
+	if res.Kind() != constant.Int {
+		return value
+	}
+	return res
+}
+
+func (r Range) excludes(value constant.Value) bool {
+	if value.Kind() == constant.Unknown {
+		return false
+	}
+	for _, excluded := range r.Excluded {
+		if constant.Compare(value, token.EQL, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmpty checks if there are no values in the range.
+//
+// It happens in unreachable code, like `if x > 0 && x < 0`,
+// and then nothing can be concluded about the value.
+func (r Range) isEmpty() bool {
+	if r.Min == nil || r.Max == nil {
+		return false
+	}
+	if constant.Compare(r.Min, token.GTR, r.Max) {
+		return true
+	}
+	return constant.Compare(r.Min, token.EQL, r.Max) && (r.MinOpen || r.MaxOpen || r.excludes(r.Min))
+}
+
+// compare checks if `x op value` is true for all values in the range,
+// false for all of them, or it depends on the exact value.
+func (r Range) compare(op token.Token, value constant.Value) tristate {
+	if r.isEmpty() || value.Kind() == constant.Unknown {
+		return unknown
+	}
+	switch op {
+	case token.LSS, token.LEQ:
+		// true if the max is less than the value, false if the min is not
+		if r.Max != nil && (constant.Compare(r.Max, op, value) ||
+			(r.MaxOpen && constant.Compare(r.Max, token.EQL, value))) {
+			return isTrue
+		}
+		if r.Min != nil && (constant.Compare(r.Min, Invert(op), value) ||
+			(r.MinOpen && constant.Compare(r.Min, token.EQL, value))) {
+			return isFalse
+		}
+	case token.GTR, token.GEQ:
+		// the same as above but for the other side of the range
+		if r.Min != nil && (constant.Compare(r.Min, op, value) ||
+			(r.MinOpen && constant.Compare(r.Min, token.EQL, value))) {
+			return isTrue
+		}
+		if r.Max != nil && (constant.Compare(r.Max, Invert(op), value) ||
+			(r.MaxOpen && constant.Compare(r.Max, token.EQL, value))) {
+			return isFalse
+		}
+	case token.EQL:
+		if r.excludes(value) {
+			return isFalse
+		}
+		if r.compare(token.LSS, value) == isTrue || r.compare(token.GTR, value) == isTrue {
+			return isFalse
+		}
+		isMin := r.Min != nil && !r.MinOpen && constant.Compare(r.Min, token.EQL, value)
+		isMax := r.Max != nil && !r.MaxOpen && constant.Compare(r.Max, token.EQL, value)
+		if isMin && isMax {
+			return isTrue
+		}
+	case token.NEQ:
+		return r.compare(token.EQL, value).not()
+	}
+	return unknown
+}
+
+// Invert returns the comparison operator that is true when the given one is false.
+// For operators that aren't comparisons, it returns token.ILLEGAL.
+//
+// For floats, it's not true for NaN. So, it should be used only for known values
+// and values that cannot be NaN.
+func Invert(op token.Token) token.Token {
+	switch op {
+	case token.LSS:
+		return token.GEQ
+	case token.LEQ:
+		return token.GTR
+	case token.GTR:
+		return token.LEQ
+	case token.GEQ:
+		return token.LSS
+	case token.EQL:
+		return token.NEQ
+	case token.NEQ:
+		return token.EQL
+	}
+	return token.ILLEGAL
+}
+
+// Mirror returns the operator for the swapped operands, `a < b` is `b > a`.
+func Mirror(op token.Token) token.Token {
+	switch op {
+	case token.LSS:
+		return token.GTR
+	case token.LEQ:
+		return token.GEQ
+	case token.GTR:
+		return token.LSS
+	case token.GEQ:
+		return token.LEQ
+	}
+	return op
+}
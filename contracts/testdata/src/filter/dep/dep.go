@@ -0,0 +1,7 @@
+package dep
+
+func Check(x int) {
+	if x < 0 {
+		panic("negative")
+	}
+}
@@ -0,0 +1,13 @@
+package filter // want package:"functions with contracts: 1"
+
+import "filter/dep"
+
+func Wrap(x int) {
+	dep.Check(x)
+}
+
+func Local(x int) { // want Local:"contracts: 1"
+	if x == 0 { // want "contract: zero"
+		panic("zero")
+	}
+}
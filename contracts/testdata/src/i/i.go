@@ -1,4 +1,4 @@
-package i
+package i // want package:"functions with contracts: 0"
 
 import (
 	_ "flag"
@@ -0,0 +1,5 @@
+package must
+
+func Fail(msg string) {
+	panic(msg)
+}
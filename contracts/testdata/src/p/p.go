@@ -1,9 +1,19 @@
-package p
+package p // want package:"functions with contracts: 25"
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	str "strings"
+	"sync"
+	"unicode/utf8"
+)
 
-func F1(in int) error {
-	if in == 0 { // want "contract: should be false: in == 0"
+func F1(in int) error { // want F1:"contracts: 3"
+	if in == 0 { // want "contract: must not be zero"
 		return errors.New("must not be zero")
 	}
 	if in == 1 { // want "contract: must not be one"
@@ -43,3 +53,448 @@ func F5(in int) error { // nolint: staticcheck
 	}
 	return nil
 }
+
+func F6(ok bool, x int, a, b int64) error { // want F6:"contracts: 4"
+	if !ok { // want "contract: should be false: !ok"
+		return invalid()
+	}
+	if -x > 0 { // want "contract: should be false: -x > 0"
+		return invalid()
+	}
+	if (a + b) > 10 { // want `contract: should be false: \(a \+ b\) > 10`
+		return invalid()
+	}
+	if int64(x) == a { // want `contract: should be false: int64\(x\) == a`
+		return invalid()
+	}
+	if &x == nil {
+		return invalid()
+	}
+	return nil
+}
+
+func F7(x int8, f float64) error { // want F7:"contracts: 2"
+	if x == math.MaxInt8 { // want "contract: should be false: x == 127"
+		return invalid()
+	}
+	if f > math.MaxInt8/2.0 { // want "contract: should be false: f > 63.5"
+		return invalid()
+	}
+	return nil
+}
+
+func F8(p *int, m map[string]int, err error) error { // want F8:"contracts: 2"
+	if p == nil { // want "contract: should be false: p == nil"
+		return invalid()
+	}
+	if m == nil || err != nil { // want "contract: should be false: m == nil || err != nil"
+		return invalid()
+	}
+	return nil
+}
+
+func F9(s string, items []int, buf []byte) error { // want F9:"contracts: 3"
+	if len(s) == 0 { // want "contract: should be false: len\\(s\\) == 0"
+		return invalid()
+	}
+	if len(items) <= len(s) { // want "contract: should be false: len\\(items\\) <= len\\(s\\)"
+		return invalid()
+	}
+	if cap(buf) < 16 { // want "contract: should be false: cap\\(buf\\) < 16"
+		return invalid()
+	}
+	if copy(buf, s) == 0 {
+		return invalid()
+	}
+	return nil
+}
+
+func F10(path string, x float64) error { // want F10:"contracts: 2"
+	if str.HasPrefix(path, "/") { // want `contract: should be false: strings.HasPrefix\(path, "/"\)`
+		return invalid()
+	}
+	if math.IsNaN(x) || !utf8.ValidString(path) { // want `contract: should be false: math.IsNaN\(x\) \|\| !utf8.ValidString\(path\)`
+		return invalid()
+	}
+	if os.Getenv(path) == "" {
+		return invalid()
+	}
+	return nil
+}
+
+const errMsg = "constant message"
+
+var errInner = errors.New("inner")
+
+func F11(x int, name string) error { // want F11:"contracts: 5"
+	if x == 0 { // want "contract: constant message"
+		return errors.New(errMsg)
+	}
+	if x == 1 { // want "contract: x must not be 1, got x"
+		return fmt.Errorf("x must not be %d, got %d", 1, x)
+	}
+	if x == 2 { // want "contract: bad name: name"
+		panic(fmt.Sprintf("bad name: %q", name))
+	}
+	if x == 3 { // want "contract: raw string"
+		panic(errors.New(`raw string`))
+	}
+	if x == 4 { // want "contract: wrapped: errInner"
+		return fmt.Errorf("wrapped: %w", errInner)
+	}
+	return nil
+}
+
+var (
+	ErrInvalidPort = errors.New("port must be non-zero")
+	ErrBadHost     = fmt.Errorf("bad host: %w", errInner)
+	ErrUnknown     error
+)
+
+type ValidationError struct {
+	Field string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid " + e.Field
+}
+
+func F12(port int, host string) error { // want F12:"contracts: 5"
+	if port == 0 { // want `contract: returns ErrInvalidPort \("port must be non-zero"\)`
+		return ErrInvalidPort
+	}
+	if port < 0 { // want `contract: returns &ValidationError{Field: "port"}`
+		return &ValidationError{Field: "port"}
+	}
+	if host == "" { // want `contract: panics with ErrBadHost \("bad host: errInner"\)`
+		panic(ErrBadHost)
+	}
+	if port == 1 { // want "contract: returns ErrUnknown"
+		return ErrUnknown
+	}
+	if port == 2 { // want "contract: returns fs.ErrNotExist"
+		return fs.ErrNotExist
+	}
+	return nil
+}
+
+type counter struct{ n int }
+
+func (c *counter) Inc() { c.n++ }
+
+var metrics counter
+
+func F13(x int) error { // want F13:"contracts: 2"
+	if x < 0 { // want "contract: x must not be negative"
+		log.Printf("bad x %d", x)
+		return errors.New("x must not be negative")
+	}
+	if x == 0 { // want "contract: x must not be zero"
+		metrics.Inc()
+		for i := 0; i < 3; i++ {
+			if i == 1 {
+				break
+			}
+		}
+		panic("x must not be zero")
+	}
+	return nil
+}
+
+func F14(x int) {
+	if x == 1 {
+		x = 2
+		panic("x is modified")
+	}
+}
+
+func F15(x int, y bool) error {
+	if x == 2 {
+		if y {
+			return nil
+		}
+		panic("might not panic")
+	}
+	return nil
+}
+
+func F16(x int) {
+	if x == 3 {
+		log.Println(&x)
+		panic("address is taken")
+	}
+}
+
+func F17(x int) {
+	if x == 0 {
+		defer func() { recover() }()
+		panic("recovered in the body")
+	}
+}
+
+var allowedModes = map[string]bool{"fast": true, "slow": true}
+
+var mutableModes = map[string]bool{"fast": true}
+
+func init() {
+	mutableModes["slow"] = true
+}
+
+func F18(s, mode string, max int) error { // want F18:"contracts: 3"
+	if n := len(s); n > max { // want "contract: too long: n"
+		return fmt.Errorf("too long: %d", n)
+	}
+	if n := max - 1; n*2 > 10 { // want `contract: should be false: \(max - 1\) \* 2 > 10`
+		return invalid()
+	}
+	if _, ok := allowedModes[mode]; !ok { // want `contract: should be false: !\(mode == "fast" \|\| mode == "slow"\)`
+		return invalid()
+	}
+	return nil
+}
+
+func F19(mode string) error {
+	if _, ok := mutableModes[mode]; !ok {
+		return invalid()
+	}
+	return nil
+}
+
+func F20(s string) error {
+	if err := validate(s); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Modes can be modified by other packages.
+var Modes = map[string]bool{"fast": true}
+
+func F21(mode string) error {
+	if _, ok := Modes[mode]; !ok {
+		return invalid()
+	}
+	return nil
+}
+
+func validate(s string) error {
+	return nil
+}
+
+func F22(n int) { // want F22:"contracts: 3"
+	switch {
+	case n < 0: // want "contract: negative"
+		panic("negative")
+	case n > 100: // want "contract: too big"
+		panic("too big")
+	}
+	if n == 42 { // want `contract: should be false: n == 42`
+		panic(fmt.Sprint(n))
+	}
+}
+
+func F23(n int) error { // want F23:"contracts: 3"
+	if n < 0 { // want "contract: negative"
+		panic("negative")
+	} else if n > 100 { // want `contract: should be false: !\(n < 0\) && \(n > 100\)`
+		return invalid()
+	} else if n == 50 {
+		println("fifty")
+	} else if n == 60 { // want "contract: sixty"
+		panic("sixty")
+	}
+	if n == 42 {
+		panic("after a non-terminating branch")
+	}
+	return nil
+}
+
+func F24(n int) error { // want F24:"contracts: 3"
+	switch n + 1 {
+	case 1, 2: // want `contract: should be false: \(n \+ 1\) == 1 \|\| \(n \+ 1\) == 2`
+		return invalid()
+	case 3:
+	case 4: // want `contract: should be false: \(n \+ 1\) != 1 && \(n \+ 1\) != 2 && \(n \+ 1\) != 3 && \(\(n \+ 1\) == 4\)`
+		return invalid()
+	case 5:
+		fallthrough
+	case 6: // want "contract: six"
+		panic("six")
+	}
+	if n == 42 {
+		panic("after fallthrough")
+	}
+	return nil
+}
+
+func F25(n int) error { // want F25:"contracts: 2"
+	if n < 0 { // want "contract: negative"
+		panic("negative")
+	} else { // want `contract: should be false: !\(n < 0\)`
+		return invalid()
+	}
+}
+
+func F26(mode string, n int) error { // want F26:"contracts: 3"
+	switch mode {
+	case "fast", "slow":
+	case "100%": // want `contract: should be false: mode != "fast" && mode != "slow" && \(mode == "100%"\)`
+		return invalid()
+	default: // want `contract: should be false: mode != "fast" && mode != "slow" && mode != "100%" \(accepted values: "fast", "slow"\)`
+		return invalid()
+	}
+	switch {
+	case n > 0:
+	default: // want `contract: should be false: !\(n > 0\)`
+		panic(n)
+	}
+	return nil
+}
+
+var mu sync.Mutex
+
+func F27(x, y int, s string) error { // want F27:"contracts: 2"
+	mu.Lock()
+	defer mu.Unlock()
+	var buf str.Builder
+	buf.WriteString(s)
+	log.Println("enter")
+	if x < 0 { // want "contract: x must not be negative"
+		panic("x must not be negative")
+	}
+	if y < 0 {
+		y = 0
+	}
+	if y > 10 {
+		panic("y is modified before")
+	}
+	if x > 10 { // want "contract: x is too big"
+		panic("x is too big")
+	}
+	if s == "" {
+		return nil
+	}
+	if x == 5 {
+		panic("might be unreachable")
+	}
+	return nil
+}
+
+func F28(x int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	if x < 0 {
+		panic("recovered")
+	}
+	return nil
+}
+
+func F29(m map[string]int, s []int, ch chan int, p *[]int, keys []string, n int) { // want F29:"contracts: 1"
+	m["a"] = 1
+	delete(m, "b")
+	copy(s, []int{1})
+	ch <- 1
+	fill(p)
+	alias := keys
+	alias[0] = ""
+	if len(m) == 0 {
+		panic("m is modified before")
+	}
+	if len(s) == 0 {
+		panic("s is modified before")
+	}
+	if len(ch) == 0 {
+		panic("ch is modified before")
+	}
+	if p == nil {
+		panic("p is modified before")
+	}
+	if len(keys) == 0 {
+		panic("keys is modified before")
+	}
+	if n == 0 { // want "contract: n is zero"
+		panic("n is zero")
+	}
+}
+
+func F30(m map[string]int, s []int) { // want F30:"contracts: 2"
+	log.Println(len(m), math.Abs(float64(len(s))))
+	_ = []byte(str.ToLower(""))
+	if len(m) == 0 { // want "contract: m is empty"
+		panic("m is empty")
+	}
+	if len(s) == 0 { // want "contract: s is empty"
+		panic("s is empty")
+	}
+}
+
+func fill(p *[]int) {
+	*p = append(*p, 1)
+}
+
+func checkDims(w, h int) { // want checkDims:"contracts: 2"
+	if w <= 0 { // want "contract: width must be positive"
+		panic("width must be positive")
+	}
+	if h <= 0 { // want "contract: height must be positive"
+		panic(fmt.Sprintf("height must be positive, got %d", h))
+	}
+}
+
+func validateName(name string) error { // want validateName:"contracts: 1"
+	if name == "" { // want "contract: empty name"
+		return errors.New("empty name")
+	}
+	return nil
+}
+
+func F31(height, width int, name string) error { // want F31:"contracts: 5"
+	checkDims(width, height)                   // want `contract: width must be positive \(via checkDims\)` `contract: height must be positive, got height \(via checkDims\)`
+	if err := validateName(name); err != nil { // want `contract: invalid name: empty name \(via validateName\)`
+		return fmt.Errorf("invalid name: %w", err)
+	}
+	if width > 100 { // want "contract: too wide"
+		panic("too wide")
+	}
+	checkDims(width, 10) // want `contract: width must be positive \(via checkDims\)`
+	return nil
+}
+
+func parse(s string, base int) (int, error) { // want parse:"contracts: 2"
+	if s == "" { // want "contract: empty input"
+		return 0, errors.New("empty input")
+	}
+	if base < 2 || base > 36 { // want "contract: invalid base"
+		panic("invalid base")
+	}
+	return len(s), nil
+}
+
+func F32(s string) (int, error) { // want F32:"contracts: 1"
+	return parse(s, 10) // want "contract: empty input \\(via parse\\)"
+}
+
+func F33(s string, base int) int { // want F33:"contracts: 1"
+	n, _ := parse(s, base) // want `contract: invalid base \(via parse\)`
+	return n
+}
+
+func F34(in string) (int, error) { // want F34:"contracts: 1"
+	n, err := F32(in) // want `contract: parse: empty input \(via F32 -> parse\)`
+	if err != nil {
+		return 0, fmt.Errorf("parse: %w", err)
+	}
+	if n > 10 {
+		panic("F32 might return an error that is not a contract")
+	}
+	return n, nil
+}
+
+// invalid returns an error without a message that can be extracted,
+// so that the contract message shows the condition.
+func invalid() error {
+	return errors.New("invalid")
+}
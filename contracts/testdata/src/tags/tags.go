@@ -0,0 +1,7 @@
+package tags
+
+func Open(path string) {
+	if path == "" {
+		panic("empty path")
+	}
+}
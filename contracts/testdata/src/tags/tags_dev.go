@@ -0,0 +1,9 @@
+//go:build dev
+
+package tags
+
+func Debug(level int) {
+	if level < 0 {
+		panic("negative level")
+	}
+}
@@ -0,0 +1,22 @@
+package term // want package:"functions with contracts: 1"
+
+import (
+	"log"
+	"must"
+	"os"
+)
+
+func F1(x int) { // want F1:"contracts: 4"
+	if x == 0 { // want "contract: x must not be zero"
+		must.Fail("x must not be zero")
+	}
+	if x == 1 { // want "contract: x must not be 1, got x"
+		log.Fatalf("x must not be 1, got %d", x)
+	}
+	if x == 2 { // want "contract: should be false: x == 2"
+		os.Exit(2)
+	}
+	if x == 3 { // want "contract: x must not be 3"
+		log.Panicln("x must not be 3")
+	}
+}
@@ -20,4 +20,6 @@ func main() {
 	_ = target(0)
 	x := 2.
 	div(x, 0)
+	y := 0.
+	div(x, y)
 }